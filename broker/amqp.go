@@ -0,0 +1,580 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// AMQP is the Transport backed by RabbitMQ.
+type AMQP struct {
+	conn *Connection
+
+	// publishing channel, opened lazily
+	pubMu sync.Mutex
+	pubCh *amqp091.Channel
+
+	// publishing channel in confirm mode for the persistent messages
+	confMu sync.Mutex
+	confCh *amqp091.Channel
+
+	cb *callback
+}
+
+// NewAMQP builds a Transport on top of a supervised connection.
+func NewAMQP(conn *Connection) *AMQP {
+	t := &AMQP{
+		conn: conn,
+	}
+	t.cb = newCallback(t)
+	return t
+}
+
+func declareExchange(ch *amqp091.Channel, topic string) error {
+	return ch.ExchangeDeclare(
+		topic,
+		"topic",
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+}
+
+func (t *AMQP) channel() (*amqp091.Channel, error) {
+	t.pubMu.Lock()
+	defer t.pubMu.Unlock()
+	if t.pubCh != nil && !t.pubCh.IsClosed() {
+		return t.pubCh, nil
+	}
+	ch, err := t.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+	t.pubCh = ch
+	return ch, nil
+}
+
+// confirmChannel returns the channel in confirm mode. The caller holds confMu
+// until the confirmation is received, so the confirmations are never mixed
+// up between publishers.
+func (t *AMQP) confirmChannel() (*amqp091.Channel, error) {
+	if t.confCh != nil && !t.confCh.IsClosed() {
+		return t.confCh, nil
+	}
+	ch, err := t.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to enable the publisher confirms: %w", err)
+	}
+	t.confCh = ch
+	return ch, nil
+}
+
+func (t *AMQP) Publish(ctx context.Context, topic string, msg Message) error {
+	if msg.Persistent {
+		return t.publishPersistent(ctx, topic, msg)
+	}
+	ch, err := t.channel()
+	if err != nil {
+		return err
+	}
+	if err := declareExchange(ch, topic); err != nil {
+		return fmt.Errorf("failed to declare exchange %s: %w", topic, err)
+	}
+	return ch.PublishWithContext(
+		ctx,
+		topic,
+		msg.RoutingKey,
+		false,
+		false,
+		amqp091.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: msg.CorrelationId,
+			ReplyTo:       msg.ReplyTo,
+			Headers:       amqp091.Table(msg.Headers),
+			Body:          msg.Body,
+		},
+	)
+}
+
+func (t *AMQP) publishPersistent(ctx context.Context, topic string, msg Message) error {
+	t.confMu.Lock()
+	defer t.confMu.Unlock()
+	ch, err := t.confirmChannel()
+	if err != nil {
+		return err
+	}
+	if err := declareExchange(ch, topic); err != nil {
+		return fmt.Errorf("failed to declare exchange %s: %w", topic, err)
+	}
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(
+		ctx,
+		topic,
+		msg.RoutingKey,
+		false,
+		false,
+		amqp091.Publishing{
+			ContentType:   "application/json",
+			DeliveryMode:  amqp091.Persistent,
+			CorrelationId: msg.CorrelationId,
+			ReplyTo:       msg.ReplyTo,
+			Headers:       amqp091.Table(msg.Headers),
+			Body:          msg.Body,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if !confirmation.Wait() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("%s was not confirmed by the broker", msg.RoutingKey)
+	}
+	return nil
+}
+
+func (t *AMQP) Consume(ctx context.Context, topic string, opts ConsumeOptions) (<-chan Delivery, error) {
+	ch, err := t.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+	if err := declareExchange(ch, topic); err != nil {
+		return nil, fmt.Errorf("failed to declare exchange %s: %w", topic, err)
+	}
+	var args amqp091.Table
+	if opts.DeadLetter {
+		if err := declareDeadLetter(ch, topic, opts.Queue); err != nil {
+			return nil, err
+		}
+		// keyed by queue since several queues may share the routing key
+		args = amqp091.Table{
+			"x-dead-letter-exchange":    DeadLetterExchange(topic),
+			"x-dead-letter-routing-key": opts.Queue,
+		}
+	}
+	q, err := ch.QueueDeclare(
+		opts.Queue,
+		true,
+		false,
+		false,
+		false,
+		args,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare queue %s: %w", opts.Queue, err)
+	}
+	err = ch.QueueBind(
+		q.Name,
+		opts.RoutingKey,
+		topic,
+		false,
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind queue %s: %w", opts.Queue, err)
+	}
+	if opts.Prefetch > 0 {
+		if err := ch.Qos(opts.Prefetch, 0, false); err != nil {
+			return nil, fmt.Errorf("failed to set the prefetch of %s: %w", opts.Queue, err)
+		}
+	}
+	msgs, err := ch.Consume(
+		q.Name,
+		opts.Consumer,
+		false,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume %s: %w", opts.Queue, err)
+	}
+
+	// the channel stays open after the consumer is cancelled so the
+	// deliveries being handled can still be acknowledged, it is closed along
+	// with the connection
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			ch.Cancel(opts.Consumer, false)
+		case <-finished:
+		}
+	}()
+	deliveries := make(chan Delivery)
+	go func() {
+		defer close(deliveries)
+		defer close(finished)
+		for d := range msgs {
+			deliveries <- amqpDelivery(ch, q.Name, d)
+		}
+	}()
+	return deliveries, nil
+}
+
+// declareDeadLetter declares the exchange and the queue receiving the
+// messages rejected from queue.
+func declareDeadLetter(ch *amqp091.Channel, topic, queue string) error {
+	exchange := DeadLetterExchange(topic)
+	if err := declareExchange(ch, exchange); err != nil {
+		return fmt.Errorf("failed to declare exchange %s: %w", exchange, err)
+	}
+	q, err := ch.QueueDeclare(
+		DeadLetterQueue(queue),
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", DeadLetterQueue(queue), err)
+	}
+	err = ch.QueueBind(
+		q.Name,
+		queue,
+		exchange,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to bind queue %s: %w", q.Name, err)
+	}
+	return nil
+}
+
+func amqpDelivery(ch *amqp091.Channel, queue string, d amqp091.Delivery) Delivery {
+	return Delivery{
+		Queue:         queue,
+		Topic:         d.Exchange,
+		RoutingKey:    d.RoutingKey,
+		CorrelationId: d.CorrelationId,
+		ReplyTo:       d.ReplyTo,
+		Headers:       d.Headers,
+		Body:          d.Body,
+		ack: func() error {
+			return d.Ack(false)
+		},
+		nack: func(requeue bool) error {
+			return d.Nack(false, requeue)
+		},
+		reply: func(ctx context.Context, body []byte) error {
+			return ch.PublishWithContext(
+				ctx,
+				d.Exchange,
+				d.ReplyTo,
+				false,
+				false,
+				amqp091.Publishing{
+					ContentType:   "application/json",
+					CorrelationId: d.CorrelationId,
+					Body:          body,
+				},
+			)
+		},
+	}
+}
+
+func (t *AMQP) Reply(ctx context.Context, d Delivery, body []byte) error {
+	return d.reply(ctx, body)
+}
+
+// Retry parks d in the retry queue of its queue. The message expires there
+// after delay and is dead lettered through the default exchange straight to
+// the queue it came from, the other queues bound to its routing key do not
+// get it again. Since
+// RabbitMQ only expires the head of a queue, a message may wait longer than
+// its delay behind a message with a longer one.
+func (t *AMQP) Retry(ctx context.Context, d Delivery, delay time.Duration, reason string) error {
+	ch, err := t.channel()
+	if err != nil {
+		return err
+	}
+	_, err = ch.QueueDeclare(
+		RetryQueue(d.Queue),
+		true,
+		false,
+		false,
+		false,
+		amqp091.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": d.Queue,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue %s: %w", RetryQueue(d.Queue), err)
+	}
+	err = ch.PublishWithContext(
+		ctx,
+		"",
+		RetryQueue(d.Queue),
+		false,
+		false,
+		amqp091.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: d.CorrelationId,
+			ReplyTo:       d.ReplyTo,
+			Headers:       amqp091.Table(retryHeaders(d, reason)),
+			Expiration:    strconv.FormatInt(delay.Milliseconds(), 10),
+			Body:          d.Body,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	return d.Ack()
+}
+
+// DeadLetters peeks the dead letter queue of queue. The messages are fetched
+// without acknowledging them and go back to the queue once the channel is
+// closed.
+func (t *AMQP) DeadLetters(topic, queue string, limit int) ([]Delivery, error) {
+	ch, err := t.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open a channel: %w", err)
+	}
+	defer ch.Close()
+
+	var deliveries []Delivery
+	for len(deliveries) < limit {
+		d, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return deliveries, fmt.Errorf("failed to get from %s: %w", DeadLetterQueue(queue), err)
+		}
+		if !ok {
+			break
+		}
+		deliveries = append(deliveries, amqpDelivery(ch, DeadLetterQueue(queue), d))
+	}
+	return deliveries, nil
+}
+
+func (t *AMQP) Request(ctx context.Context, topic string, msg Message) ([]byte, error) {
+	return t.cb.request(ctx, topic, msg)
+}
+
+func (t *AMQP) NotifyReconnect(receiver chan struct{}) chan struct{} {
+	return t.conn.NotifyReconnect(receiver)
+}
+
+func (t *AMQP) Close() error {
+	return t.conn.Close()
+}
+
+// call is a request waiting for its reply.
+type call struct {
+	topic    string
+	msg      Message
+	response chan []byte
+}
+
+// callback owns the single reply queue of this process. Every request is
+// published with its own call id and the reply is handed back to the caller
+// waiting on that id. When the connection is re-established the queue is
+// declared again and the calls still waiting are published again.
+type callback struct {
+	seq     uint64
+	t       *AMQP
+	startMu sync.Mutex
+	mu      sync.Mutex
+	ch      *amqp091.Channel
+	queue   string
+	topics  map[string]bool
+	pending map[string]*call
+	started bool
+}
+
+func newCallback(t *AMQP) *callback {
+	return &callback{
+		t:       t,
+		topics:  map[string]bool{},
+		pending: map[string]*call{},
+	}
+}
+
+// setup declares a fresh reply queue on the current connection and starts
+// dispatching the replies.
+func (cb *callback) setup() error {
+	ch, err := cb.t.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open a channel: %w", err)
+	}
+	q, err := ch.QueueDeclare(
+		"",
+		false,
+		true,
+		true,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare queue: %w", err)
+	}
+	msgs, err := ch.Consume(
+		q.Name,
+		"callback",
+		true,
+		true,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to listen the callback: %w", err)
+	}
+	cb.mu.Lock()
+	cb.ch = ch
+	cb.queue = q.Name
+	topics := cb.topics
+	cb.topics = map[string]bool{}
+	cb.mu.Unlock()
+	for topic := range topics {
+		if err := cb.bind(topic); err != nil {
+			return err
+		}
+	}
+	go cb.dispatch(msgs)
+	return nil
+}
+
+// bind binds the reply queue on topic. Services reply to the exchange the
+// request came from, so the queue is bound on every topic a request was sent
+// to, using its own name as the routing key.
+func (cb *callback) bind(topic string) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.topics[topic] {
+		return nil
+	}
+	if err := declareExchange(cb.ch, topic); err != nil {
+		return fmt.Errorf("failed to declare exchange %s: %w", topic, err)
+	}
+	err := cb.ch.QueueBind(
+		cb.queue,
+		cb.queue,
+		topic,
+		false,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to bind queue: %w", err)
+	}
+	cb.topics[topic] = true
+	return nil
+}
+
+// start declares the reply queue on the first request.
+func (cb *callback) start() error {
+	cb.mu.Lock()
+	started := cb.started
+	cb.mu.Unlock()
+	if started {
+		return nil
+	}
+	cb.startMu.Lock()
+	defer cb.startMu.Unlock()
+	if cb.started {
+		return nil
+	}
+	if err := cb.setup(); err != nil {
+		return err
+	}
+	go cb.supervise()
+	cb.mu.Lock()
+	cb.started = true
+	cb.mu.Unlock()
+	return nil
+}
+
+func (cb *callback) supervise() {
+	for range cb.t.conn.NotifyReconnect(make(chan struct{}, 1)) {
+		if err := cb.setup(); err != nil {
+			log.Printf("Failed to create the callback queue: %s", err)
+			continue
+		}
+		// the services may run a call twice when the request got through
+		// but the reply was lost with the old queue
+		cb.mu.Lock()
+		calls := make(map[string]*call, len(cb.pending))
+		for callId, c := range cb.pending {
+			calls[callId] = c
+		}
+		cb.mu.Unlock()
+		for callId, c := range calls {
+			log.Printf("Retrying %s with call-id: %s", c.msg.RoutingKey, callId)
+			if err := cb.publish(context.Background(), c); err != nil {
+				log.Printf("Failed to retry %s: %s", c.msg.RoutingKey, err)
+			}
+		}
+	}
+}
+
+func (cb *callback) dispatch(msgs <-chan amqp091.Delivery) {
+	for d := range msgs {
+		cb.mu.Lock()
+		c, ok := cb.pending[d.CorrelationId]
+		delete(cb.pending, d.CorrelationId)
+		cb.mu.Unlock()
+		if !ok {
+			log.Printf("Dropping reply with unknown correlation-id: %s", d.CorrelationId)
+			continue
+		}
+		c.response <- d.Body
+	}
+}
+
+func (cb *callback) publish(ctx context.Context, c *call) error {
+	if err := cb.bind(c.topic); err != nil {
+		return err
+	}
+	cb.mu.Lock()
+	msg := c.msg
+	msg.ReplyTo = cb.queue
+	cb.mu.Unlock()
+	return cb.t.Publish(ctx, c.topic, msg)
+}
+
+func (cb *callback) request(ctx context.Context, topic string, msg Message) ([]byte, error) {
+	if err := cb.start(); err != nil {
+		return nil, err
+	}
+	msg.CorrelationId = fmt.Sprintf("%s-%d", msg.CorrelationId, atomic.AddUint64(&cb.seq, 1))
+	c := &call{
+		topic:    topic,
+		msg:      msg,
+		response: make(chan []byte, 1),
+	}
+	cb.mu.Lock()
+	cb.pending[msg.CorrelationId] = c
+	cb.mu.Unlock()
+	defer func() {
+		cb.mu.Lock()
+		delete(cb.pending, msg.CorrelationId)
+		cb.mu.Unlock()
+	}()
+
+	// a call that cannot be published because the connection is down is
+	// published again once it is back
+	if err := cb.publish(ctx, c); err != nil {
+		log.Printf("Failed to publish to %s, waiting for reconnection: %s", msg.RoutingKey, err)
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case body := <-c.response:
+		return body, nil
+	}
+}
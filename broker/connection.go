@@ -0,0 +1,128 @@
+package broker
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+const RECONNECT_MIN_DELAY = time.Second
+const RECONNECT_MAX_DELAY = 30 * time.Second
+
+var ErrClosed = errors.New("connection closed")
+
+// Connection keeps a RabbitMQ connection alive. When the broker drops the
+// connection it is dialed again with exponential backoff and every channel
+// registered through NotifyReconnect is signalled, so the owner can declare
+// its exchanges, queues and consumers again.
+type Connection struct {
+	uri       string
+	mu        sync.RWMutex
+	conn      *amqp091.Connection
+	listeners []chan struct{}
+	closed    bool
+}
+
+// Dial blocks until the broker is reachable and then supervises the
+// connection in the background.
+func Dial(uri string) *Connection {
+	c := &Connection{uri: uri}
+	c.conn = c.redial()
+	go c.supervise()
+	return c
+}
+
+func (c *Connection) redial() *amqp091.Connection {
+	delay := RECONNECT_MIN_DELAY
+	for {
+		conn, err := amqp091.Dial(c.uri)
+		if err == nil {
+			log.Println("Successfully connected to RabbitMQ Server !")
+			return conn
+		}
+		log.Printf("Failed to connect to RabbitMQ: %s, retrying in %s", err, delay)
+		time.Sleep(delay)
+		delay = delay * 2
+		if delay > RECONNECT_MAX_DELAY {
+			delay = RECONNECT_MAX_DELAY
+		}
+	}
+}
+
+func (c *Connection) supervise() {
+	for {
+		c.mu.RLock()
+		conn := c.conn
+		c.mu.RUnlock()
+
+		reason := <-conn.NotifyClose(make(chan *amqp091.Error, 1))
+		if c.isClosed() {
+			return
+		}
+		log.Printf("RabbitMQ connection lost: %v, reconnecting...", reason)
+		conn = c.redial()
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return
+		}
+		c.conn = conn
+		for _, l := range c.listeners {
+			select {
+			case l <- struct{}{}:
+			default:
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+func (c *Connection) isClosed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.closed
+}
+
+// Channel opens a channel on the current connection.
+func (c *Connection) Channel() (*amqp091.Channel, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return nil, ErrClosed
+	}
+	return c.conn.Channel()
+}
+
+// NotifyReconnect registers a channel that receives a value every time the
+// connection has been re-established. Sends never block, so the channel
+// should be buffered.
+func (c *Connection) NotifyReconnect(receiver chan struct{}) chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, receiver)
+	return receiver
+}
+
+// Close stops the supervisor and closes the underlying connection.
+func (c *Connection) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	listeners := c.listeners
+	c.listeners = nil
+	conn := c.conn
+	c.mu.Unlock()
+
+	for _, l := range listeners {
+		close(l)
+	}
+	return conn.Close()
+}
@@ -0,0 +1,252 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MEMORY_QUEUE_SIZE is the number of messages a queue of the in-memory
+// transport holds before Publish blocks.
+const MEMORY_QUEUE_SIZE = 1024
+
+// Memory is an in-process Transport. It routes the messages the same way
+// RabbitMQ does for the exact routing keys used by the services, which is
+// enough to run the gateway and every service inside a single binary.
+type Memory struct {
+	seq uint64
+
+	// mu guards the queues and their bindings. The messages are sent
+	// outside of it, waiting on done as well, so Close never waits for a
+	// full queue.
+	mu        sync.RWMutex
+	queues    map[string]chan Delivery
+	bindings  map[string]map[string]bool
+	listeners []chan struct{}
+	closed    bool
+	done      chan struct{}
+
+	deadMu sync.Mutex
+	dead   map[string][]Delivery
+	dlq    map[string]bool
+
+	pendingMu sync.Mutex
+	pending   map[string]chan []byte
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		queues:   map[string]chan Delivery{},
+		bindings: map[string]map[string]bool{},
+		dead:     map[string][]Delivery{},
+		dlq:      map[string]bool{},
+		pending:  map[string]chan []byte{},
+		done:     make(chan struct{}),
+	}
+}
+
+func bindingKey(topic, routingKey string) string {
+	return topic + "/" + routingKey
+}
+
+func (m *Memory) Publish(ctx context.Context, topic string, msg Message) error {
+	m.mu.RLock()
+	if m.closed {
+		m.mu.RUnlock()
+		return ErrClosed
+	}
+	var queues []chan Delivery
+	var deliveries []Delivery
+	for queue := range m.bindings[bindingKey(topic, msg.RoutingKey)] {
+		q := m.queues[queue]
+		queues = append(queues, q)
+		deliveries = append(deliveries, m.delivery(topic, queue, msg, q))
+	}
+	m.mu.RUnlock()
+
+	for i, q := range queues {
+		select {
+		case q <- deliveries[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-m.done:
+			return ErrClosed
+		}
+	}
+	return nil
+}
+
+// send delivers d to q, unless the transport is closed first.
+func (m *Memory) send(q chan Delivery, d Delivery) {
+	select {
+	case q <- d:
+	case <-m.done:
+	}
+}
+
+func (m *Memory) delivery(topic, queue string, msg Message, q chan Delivery) Delivery {
+	d := Delivery{
+		Queue:         queue,
+		Topic:         topic,
+		RoutingKey:    msg.RoutingKey,
+		CorrelationId: msg.CorrelationId,
+		ReplyTo:       msg.ReplyTo,
+		Headers:       msg.Headers,
+		Body:          msg.Body,
+	}
+	d.ack = func() error {
+		return nil
+	}
+	d.nack = func(requeue bool) error {
+		if !requeue {
+			m.deadMu.Lock()
+			if m.dlq[queue] {
+				m.dead[queue] = append(m.dead[queue], d)
+			}
+			m.deadMu.Unlock()
+			return nil
+		}
+		go m.send(q, d)
+		return nil
+	}
+	d.reply = func(ctx context.Context, body []byte) error {
+		m.pendingMu.Lock()
+		response, ok := m.pending[d.CorrelationId]
+		delete(m.pending, d.CorrelationId)
+		m.pendingMu.Unlock()
+		if ok {
+			response <- body
+		}
+		return nil
+	}
+	return d
+}
+
+func (m *Memory) Consume(ctx context.Context, topic string, opts ConsumeOptions) (<-chan Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	q, ok := m.queues[opts.Queue]
+	if !ok {
+		q = make(chan Delivery, MEMORY_QUEUE_SIZE)
+		m.queues[opts.Queue] = q
+	}
+	key := bindingKey(topic, opts.RoutingKey)
+	if m.bindings[key] == nil {
+		m.bindings[key] = map[string]bool{}
+	}
+	m.bindings[key][opts.Queue] = true
+	m.deadMu.Lock()
+	m.dlq[opts.Queue] = opts.DeadLetter
+	m.deadMu.Unlock()
+
+	// every consumer of a queue competes on the same channel
+	deliveries := make(chan Delivery)
+	go func() {
+		defer close(deliveries)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-m.done:
+				return
+			case d := <-q:
+				select {
+				case deliveries <- d:
+				case <-ctx.Done():
+					d.Nack(true)
+					return
+				case <-m.done:
+					return
+				}
+			}
+		}
+	}()
+	return deliveries, nil
+}
+
+func (m *Memory) Request(ctx context.Context, topic string, msg Message) ([]byte, error) {
+	msg.CorrelationId = fmt.Sprintf("%s-%d", msg.CorrelationId, atomic.AddUint64(&m.seq, 1))
+	response := make(chan []byte, 1)
+	m.pendingMu.Lock()
+	m.pending[msg.CorrelationId] = response
+	m.pendingMu.Unlock()
+	defer func() {
+		m.pendingMu.Lock()
+		delete(m.pending, msg.CorrelationId)
+		m.pendingMu.Unlock()
+	}()
+
+	if err := m.Publish(ctx, topic, msg); err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case body := <-response:
+		return body, nil
+	}
+}
+
+func (m *Memory) Reply(ctx context.Context, d Delivery, body []byte) error {
+	return d.reply(ctx, body)
+}
+
+func (m *Memory) Retry(ctx context.Context, d Delivery, delay time.Duration, reason string) error {
+	m.mu.RLock()
+	q, ok := m.queues[d.Queue]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown queue %s", d.Queue)
+	}
+	retried := m.delivery(d.Topic, d.Queue, Message{
+		RoutingKey:    d.RoutingKey,
+		CorrelationId: d.CorrelationId,
+		ReplyTo:       d.ReplyTo,
+		Headers:       retryHeaders(d, reason),
+		Body:          d.Body,
+	}, q)
+	time.AfterFunc(delay, func() {
+		m.send(q, retried)
+	})
+	return d.Ack()
+}
+
+func (m *Memory) DeadLetters(topic, queue string, limit int) ([]Delivery, error) {
+	m.deadMu.Lock()
+	defer m.deadMu.Unlock()
+	dead := m.dead[queue]
+	if len(dead) > limit {
+		dead = dead[:limit]
+	}
+	return append([]Delivery(nil), dead...), nil
+}
+
+// NotifyReconnect never signals since the in-memory transport can not be
+// disconnected, the receiver is only closed by Close.
+func (m *Memory) NotifyReconnect(receiver chan struct{}) chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, receiver)
+	return receiver
+}
+
+// Close stops every consumer.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	close(m.done)
+	for _, l := range m.listeners {
+		close(l)
+	}
+	m.listeners = nil
+	return nil
+}
@@ -0,0 +1,160 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testTopic = "test"
+
+// receive returns the next delivery of msgs, failing the test when none
+// comes in time.
+func receive(t *testing.T, msgs <-chan Delivery) Delivery {
+	t.Helper()
+	select {
+	case d, ok := <-msgs:
+		require.True(t, ok, "the consumer stopped")
+		return d
+	case <-time.After(time.Second):
+		require.FailNow(t, "no message delivered")
+		return Delivery{}
+	}
+}
+
+func TestMemory_RequestReply(t *testing.T) {
+	m := NewMemory()
+	defer m.Close()
+	ctx := context.Background()
+	msgs, err := m.Consume(ctx, testTopic, ConsumeOptions{Queue: "ping", RoutingKey: "ping"})
+	require.NoError(t, err)
+
+	go func() {
+		d := <-msgs
+		m.Reply(ctx, d, append([]byte("pong "), d.Body...))
+		d.Ack()
+	}()
+	body, err := m.Request(ctx, testTopic, Message{RoutingKey: "ping", CorrelationId: "c", Body: []byte("1")})
+	require.NoError(t, err)
+	require.Equal(t, "pong 1", string(body))
+}
+
+func TestMemory_RequestTimeout(t *testing.T) {
+	m := NewMemory()
+	defer m.Close()
+	_, err := m.Consume(context.Background(), testTopic, ConsumeOptions{Queue: "ping", RoutingKey: "ping"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = m.Request(ctx, testTopic, Message{RoutingKey: "ping", CorrelationId: "c"})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMemory_Retry(t *testing.T) {
+	m := NewMemory()
+	defer m.Close()
+	ctx := context.Background()
+	msgs, err := m.Consume(ctx, testTopic, ConsumeOptions{Queue: "work", RoutingKey: "work"})
+	require.NoError(t, err)
+	require.NoError(t, m.Publish(ctx, testTopic, Message{RoutingKey: "work", CorrelationId: "c", Body: []byte("job")}))
+
+	d := receive(t, msgs)
+	require.Equal(t, 0, d.Attempt())
+	require.NoError(t, m.Retry(ctx, d, 10*time.Millisecond, "failed once"))
+
+	d = receive(t, msgs)
+	require.Equal(t, 1, d.Attempt())
+	require.Equal(t, "failed once", d.Headers[HEADER_ERROR])
+	require.Equal(t, "job", string(d.Body))
+	require.Equal(t, "c", d.CorrelationId)
+	require.NoError(t, m.Retry(ctx, d, 0, "failed twice"))
+
+	d = receive(t, msgs)
+	require.Equal(t, 2, d.Attempt())
+	require.Equal(t, "failed twice", d.Headers[HEADER_ERROR])
+}
+
+func TestMemory_Requeue(t *testing.T) {
+	m := NewMemory()
+	defer m.Close()
+	ctx := context.Background()
+	msgs, err := m.Consume(ctx, testTopic, ConsumeOptions{Queue: "work", RoutingKey: "work"})
+	require.NoError(t, err)
+	require.NoError(t, m.Publish(ctx, testTopic, Message{RoutingKey: "work", Body: []byte("job")}))
+
+	require.NoError(t, receive(t, msgs).Nack(true))
+	require.Equal(t, "job", string(receive(t, msgs).Body))
+}
+
+func TestMemory_DeadLetter(t *testing.T) {
+	m := NewMemory()
+	defer m.Close()
+	ctx := context.Background()
+	dead, err := m.Consume(ctx, testTopic, ConsumeOptions{Queue: "dead", RoutingKey: "dead", DeadLetter: true})
+	require.NoError(t, err)
+	dropped, err := m.Consume(ctx, testTopic, ConsumeOptions{Queue: "dropped", RoutingKey: "dropped"})
+	require.NoError(t, err)
+
+	for _, body := range []string{"first", "second"} {
+		require.NoError(t, m.Publish(ctx, testTopic, Message{RoutingKey: "dead", Body: []byte(body)}))
+		require.NoError(t, receive(t, dead).Nack(false))
+	}
+	require.NoError(t, m.Publish(ctx, testTopic, Message{RoutingKey: "dropped", Body: []byte("lost")}))
+	require.NoError(t, receive(t, dropped).Nack(false))
+
+	letters, err := m.DeadLetters(testTopic, "dead", 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	require.Equal(t, "first", string(letters[0].Body))
+	require.Equal(t, "second", string(letters[1].Body))
+
+	letters, err = m.DeadLetters(testTopic, "dead", 1)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+
+	letters, err = m.DeadLetters(testTopic, "dropped", 10)
+	require.NoError(t, err)
+	require.Len(t, letters, 0)
+}
+
+func TestMemory_CloseWithFullQueue(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	msgs, err := m.Consume(ctx, testTopic, ConsumeOptions{Queue: "full", RoutingKey: "full"})
+	require.NoError(t, err)
+	// one message is held by the consumer, the others fill the queue
+	for i := 0; i <= MEMORY_QUEUE_SIZE; i++ {
+		require.NoError(t, m.Publish(ctx, testTopic, Message{RoutingKey: "full"}))
+	}
+	d := receive(t, msgs)
+	// the consumer took the next message, the queue is filled again
+	require.NoError(t, m.Publish(ctx, testTopic, Message{RoutingKey: "full"}))
+
+	published := make(chan error, 1)
+	go func() {
+		published <- m.Publish(ctx, testTopic, Message{RoutingKey: "full"})
+	}()
+	require.NoError(t, m.Retry(ctx, d, 0, "retried on a full queue"))
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- m.Close()
+	}()
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		require.FailNow(t, "Close blocked on the full queue")
+	}
+	select {
+	case err := <-published:
+		require.ErrorIs(t, err, ErrClosed)
+	case <-time.After(time.Second):
+		require.FailNow(t, "Publish blocked after Close")
+	}
+	require.ErrorIs(t, m.Publish(ctx, testTopic, Message{RoutingKey: "full"}), ErrClosed)
+}
@@ -0,0 +1,131 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Transport carries the messages between the gateway and the services. The
+// routing follows the RabbitMQ topology: messages are published to a topic
+// with a routing key, and a queue receives every message whose routing key it
+// is bound to.
+type Transport interface {
+	// Publish sends msg to topic without waiting for any reply.
+	Publish(ctx context.Context, topic string, msg Message) error
+	// Consume declares the queue, binds it to its routing key on topic and
+	// delivers its messages until ctx is done, the transport is closed or
+	// the connection drops. The deliveries received before ctx is done can
+	// still be acknowledged.
+	Consume(ctx context.Context, topic string, opts ConsumeOptions) (<-chan Delivery, error)
+	// Request publishes msg to topic and blocks until the consumer replies
+	// or ctx is done.
+	Request(ctx context.Context, topic string, msg Message) ([]byte, error)
+	// Reply sends body back to the caller waiting on d. The delivery still
+	// has to be acknowledged.
+	Reply(ctx context.Context, d Delivery, body []byte) error
+	// Retry acknowledges d and delivers it again to its queue after delay,
+	// with the attempt header incremented and reason as the last error.
+	Retry(ctx context.Context, d Delivery, delay time.Duration, reason string) error
+	// DeadLetters returns up to limit messages rejected from queue on
+	// topic, without removing them.
+	DeadLetters(topic, queue string, limit int) ([]Delivery, error)
+	// NotifyReconnect registers a channel signalled every time the
+	// consumers have to be declared again.
+	NotifyReconnect(receiver chan struct{}) chan struct{}
+	Close() error
+}
+
+// HEADER_ATTEMPT counts how many times a message has been retried.
+const HEADER_ATTEMPT = "x-attempt"
+
+// HEADER_ERROR holds the error of the last failed attempt.
+const HEADER_ERROR = "x-error"
+
+// ConsumeOptions describes the queue a consumer listens on.
+type ConsumeOptions struct {
+	Queue      string
+	RoutingKey string
+	Consumer   string
+	// Prefetch limits the unacknowledged messages held by the consumer, no
+	// limit when zero.
+	Prefetch int
+	// DeadLetter sends the messages rejected with Nack(false) to the dead
+	// letter queue of Queue instead of dropping them.
+	DeadLetter bool
+}
+
+func DeadLetterExchange(topic string) string {
+	return fmt.Sprintf("%s-dead-letter", topic)
+}
+
+func DeadLetterQueue(queue string) string {
+	return fmt.Sprintf("%s_dead_letter", queue)
+}
+
+// RetryQueue is named apart from the former "<queue>_retry" queues, which
+// dead letter by routing key, since RabbitMQ refuses to declare a queue
+// again with other arguments. The former ones are left to drain.
+func RetryQueue(queue string) string {
+	return fmt.Sprintf("%s_delayed", queue)
+}
+
+// Message is published through a Transport.
+type Message struct {
+	RoutingKey    string
+	CorrelationId string
+	ReplyTo       string
+	Headers       map[string]interface{}
+	Body          []byte
+	// Persistent messages are written to disk by the broker, and Publish
+	// only returns once the broker confirmed it received them.
+	Persistent bool
+}
+
+// Delivery is a message received from a Transport. It must be acknowledged
+// with Ack, Nack or Retry.
+type Delivery struct {
+	Queue         string
+	Topic         string
+	RoutingKey    string
+	CorrelationId string
+	ReplyTo       string
+	Headers       map[string]interface{}
+	Body          []byte
+
+	ack   func() error
+	nack  func(requeue bool) error
+	reply func(ctx context.Context, body []byte) error
+}
+
+func (d Delivery) Ack() error {
+	return d.ack()
+}
+
+func (d Delivery) Nack(requeue bool) error {
+	return d.nack(requeue)
+}
+
+// Attempt returns how many times d has been retried.
+func (d Delivery) Attempt() int {
+	switch v := d.Headers[HEADER_ATTEMPT].(type) {
+	case int:
+		return v
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	return 0
+}
+
+// retryHeaders copies the headers of d for its next attempt.
+func retryHeaders(d Delivery, reason string) map[string]interface{} {
+	headers := map[string]interface{}{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[HEADER_ATTEMPT] = int32(d.Attempt() + 1)
+	headers[HEADER_ERROR] = reason
+	return headers
+}
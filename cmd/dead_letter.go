@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"wahyuade.com/simple-e-commerce/broker"
+	"wahyuade.com/simple-e-commerce/services"
+)
+
+var deadLetterLimit int
+
+var moduleTopics = map[string]string{
+	"user":        services.TOPIC_USER,
+	"product":     services.TOPIC_PRODUCT,
+	"transaction": services.TOPIC_TRANSACTION,
+	"order":       services.TOPIC_ORDER,
+	"events":      services.TOPIC_DOMAIN_EVENTS,
+}
+
+func init() {
+	runDeadLetterCmd.Flags().IntVar(&deadLetterLimit, "limit", 20, "maximum number of messages to show")
+	rootCmd.AddCommand(runDeadLetterCmd)
+}
+
+var runDeadLetterCmd = &cobra.Command{
+	Use:   "dead-letter [module] [queue]",
+	Short: "Show the failed requests of a queue",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		topic, ok := moduleTopics[args[0]]
+		if !ok {
+			fmt.Printf("Error: unknown module %s\n", args[0])
+			os.Exit(1)
+		}
+		t := services.DialTransport()
+		defer t.Close()
+
+		deliveries, err := services.DeadLetters(t, topic, args[1], deadLetterLimit)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(1)
+		}
+		if len(deliveries) == 0 {
+			fmt.Println("No failed request")
+			return
+		}
+		for _, d := range deliveries {
+			fmt.Printf("correlation-id: %s\n", d.CorrelationId)
+			fmt.Printf("attempts:       %d\n", d.Attempt()+1)
+			fmt.Printf("last error:     %v\n", d.Headers[broker.HEADER_ERROR])
+			fmt.Printf("body:           %s\n", d.Body)
+			fmt.Println()
+		}
+	},
+}
@@ -1,7 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -11,6 +17,15 @@ import (
 	"wahyuade.com/simple-e-commerce/schemas"
 )
 
+// requestTimeout is the deadline given to every graphql request, including
+// all the RPC calls made while resolving it.
+var requestTimeout time.Duration
+
+func init() {
+	runGraphqlCmd.Flags().DurationVar(&requestTimeout, "timeout", 10*time.Second, "deadline for each graphql request")
+	runGraphqlCmd.Flags().DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "time given to the requests being handled when stopping")
+}
+
 var runGraphqlCmd = &cobra.Command{
 	Use:   "graphql",
 	Short: "Run the graphql server",
@@ -24,19 +39,63 @@ var runGraphqlCmd = &cobra.Command{
 		graphQL.All("/product", routingSchema(s.Product()))
 		graphQL.All("/transaction", routingSchema(s.Transaction()))
 		graphQL.All("/order", routingSchema(s.Order()))
-		web.Listen("0.0.0.0:7000")
+		web.Post("/webhook/payment/:provider", paymentWebhook(s.Transaction().RPC()))
+
+		failed := make(chan error, 1)
+		go func() {
+			failed <- web.Listen("0.0.0.0:7000")
+		}()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		select {
+		case err := <-failed:
+			log.Println(err)
+			os.Exit(1)
+		case <-ctx.Done():
+		}
+		stop()
+
+		log.Println("Shutting down...")
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- web.Shutdown()
+		}()
+		select {
+		case err := <-stopped:
+			if err != nil {
+				log.Println(err)
+				os.Exit(1)
+			}
+		case <-time.After(shutdownTimeout):
+			log.Println("Requests still being handled after the shutdown timeout")
+			os.Exit(1)
+		}
+		if err := s.Close(); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		log.Println("Graphql server stopped")
 	},
 }
 
 func routingSchema(schema schemas.Schema) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+
 		s := schema.Context(c)
 		uuid := uuid.NewString()
 		c.Locals("uuid", uuid)
 		c.Set("correlation-id", uuid)
 		headers := c.GetReqHeaders()
 		authorization := headers["Authorization"]
-		user := s.RPC().User().GetBySession(c.Context(), uuid, authorization)
+		user, err := s.RPC().User().GetBySession(ctx, uuid, authorization)
+		if err != nil && !schemas.IsNotFound(err) {
+			log.Println(err)
+			if schemas.IsTimeout(err) {
+				return errorResponse(c, fiber.StatusGatewayTimeout, "Layanan sedang tidak merespon, silahkan coba lagi")
+			}
+			return errorResponse(c, fiber.StatusInternalServerError, "Internal server error")
+		}
 		c.Locals("user", user)
 		if s.Scope() == "private" {
 			if authorization == "" {
@@ -50,23 +109,29 @@ func routingSchema(schema schemas.Schema) func(c *fiber.Ctx) error {
 			Schema: s.Config(),
 			Pretty: true,
 		})
-		fasthttpadaptor.NewFastHTTPHandler(h)(c.Context())
+		fasthttpadaptor.NewFastHTTPHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ContextHandler(ctx, w, r)
+		})(c.Context())
 		return nil
 	}
 }
 
 func unAuthorized(c *fiber.Ctx) error {
+	return errorResponse(c, 403, "Silahkan login terlebih dahulu")
+}
+
+func errorResponse(c *fiber.Ctx, status int, message string) error {
 	type Err struct {
 		Message string `json:"message"`
 	}
-	return c.Status(403).JSON(struct {
-		Data   interface{} `json:data`
+	return c.Status(status).JSON(struct {
+		Data   interface{} `json:"data"`
 		Errors []Err       `json:"errors"`
 	}{
 		Data: nil,
 		Errors: []Err{
 			Err{
-				Message: "Silahkan login terlebih dahulu",
+				Message: message,
 			},
 		},
 	})
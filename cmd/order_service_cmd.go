@@ -1,9 +1,6 @@
 package cmd
 
 import (
-	"bufio"
-	"os"
-
 	"github.com/spf13/cobra"
 	"wahyuade.com/simple-e-commerce/services"
 )
@@ -12,10 +9,8 @@ var runOrderServiceCmd = &cobra.Command{
 	Use:   "order",
 	Short: "Run the order service",
 	Run: func(cmd *cobra.Command, args []string) {
-		service := services.New()
+		service := newService(":7004")
 		service.Bootstrap(services.OrderService{})
-
-		reader := bufio.NewReader(os.Stdin)
-		reader.ReadString('\n')
+		waitForShutdown(service)
 	},
 }
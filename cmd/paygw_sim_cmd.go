@@ -0,0 +1,269 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+// NOTIFICATION_ATTEMPTS is how many times the simulator sends a payment
+// notification the gateway fails to handle, waiting a second longer before
+// each new attempt.
+const NOTIFICATION_ATTEMPTS = 3
+
+var simAddr string
+var simGatewayUrl string
+
+// simTimeout is the deadline of every inquiry and notification of the
+// simulator.
+var simTimeout time.Duration
+
+func init() {
+	runStartServiceCmd.AddCommand(runPaygwSimCmd)
+	runPaygwSimCmd.Flags().StringVar(&simAddr, "addr", ":7010", "address the simulator listens on")
+	runPaygwSimCmd.Flags().StringVar(&simGatewayUrl, "gateway-url", "http://localhost:7000", "base url of the gateway receiving the payment notifications")
+	runPaygwSimCmd.Flags().DurationVar(&simTimeout, "timeout", 10*time.Second, "deadline for each inquiry and notification")
+}
+
+var runPaygwSimCmd = &cobra.Command{
+	Use:   "paygw-sim",
+	Short: "Run the payment gateway simulator",
+	Run: func(cmd *cobra.Command, args []string) {
+		secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+		if secret == "" {
+			log.Fatalln("PAYMENT_WEBHOOK_SECRET has to be set to sign the payment notifications")
+		}
+		sim := &paygwSim{
+			rpc:        schemas.InitRpc(),
+			secret:     secret,
+			gatewayUrl: simGatewayUrl,
+			client:     &http.Client{Timeout: simTimeout},
+			payments:   map[string]simPayment{},
+		}
+		web := fiber.New()
+		web.Get("/va/:provider/:virtual_account", sim.inquiry)
+		web.Post("/va/:provider/:virtual_account/pay", sim.pay)
+		web.Get("/payments", sim.listPayments)
+		web.Post("/payments/:reference/notify", sim.notifyAgain)
+
+		log.Printf("Payment gateway simulator is listening on %s...", simAddr)
+		failed := make(chan error, 1)
+		go func() {
+			failed <- web.Listen(simAddr)
+		}()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		select {
+		case err := <-failed:
+			log.Println(err)
+			os.Exit(1)
+		case <-ctx.Done():
+		}
+		stop()
+
+		log.Println("Shutting down...")
+		if err := web.Shutdown(); err != nil {
+			log.Println(err)
+		}
+		if err := sim.rpc.Close(); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
+		log.Println("Payment gateway simulator stopped")
+	},
+}
+
+// paygwSim plays the bank: it looks the virtual accounts up in the
+// transaction service and notifies the gateway of their payment like a
+// payment provider would. The payments are kept in memory.
+type paygwSim struct {
+	rpc        schemas.Rpc
+	secret     string
+	gatewayUrl string
+	client     *http.Client
+
+	mu       sync.Mutex
+	payments map[string]simPayment
+}
+
+type simPayment struct {
+	Provider     string                      `json:"provider"`
+	Notification schemas.PaymentNotification `json:"notification"`
+	// the answer of the gateway to the last notification
+	NotifiedStatus int             `json:"notified_status"`
+	NotifiedBody   json.RawMessage `json:"notified_body,omitempty"`
+}
+
+// inquiry returns the bill of the virtual account, like the bank shows it
+// before the user pays.
+func (s *paygwSim) inquiry(c *fiber.Ctx) error {
+	provider, trx, status, message := s.lookup(c)
+	if status != fiber.StatusOK {
+		return errorResponse(c, status, message)
+	}
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{
+			"provider":        provider.Code,
+			"virtual_account": trx.VirtualAccount,
+			"amount":          trx.Amount,
+			"paid":            trx.Paid,
+			"status":          trx.Status,
+			"expired":         trx.Expired,
+		},
+	})
+}
+
+// pay pays the virtual account and notifies the gateway. The amount defaults
+// to what is left to pay of the bill and the reference to a new one, sending
+// a used reference again replays its notification.
+func (s *paygwSim) pay(c *fiber.Ctx) error {
+	var req struct {
+		Amount    int    `json:"amount"`
+		Reference string `json:"reference"`
+	}
+	if len(c.Body()) > 0 {
+		if err := json.Unmarshal(c.Body(), &req); err != nil {
+			return errorResponse(c, fiber.StatusBadRequest, "Format permintaan tidak valid")
+		}
+	}
+	provider, trx, status, message := s.lookup(c)
+	if status != fiber.StatusOK {
+		return errorResponse(c, status, message)
+	}
+	if req.Amount == 0 {
+		req.Amount = trx.Amount - trx.Paid
+	}
+	if req.Reference == "" {
+		req.Reference = fmt.Sprintf("%s-%s", provider.Code, uuid.NewString())
+	}
+	payment := simPayment{
+		Provider: provider.Code,
+		Notification: schemas.PaymentNotification{
+			VirtualAccount: trx.VirtualAccount,
+			Reference:      req.Reference,
+			Amount:         req.Amount,
+			PaidAt:         time.Now().Truncate(time.Second),
+		},
+	}
+	payment = s.notify(c.UserContext(), payment)
+	return c.JSON(fiber.Map{"data": payment})
+}
+
+func (s *paygwSim) listPayments(c *fiber.Ctx) error {
+	s.mu.Lock()
+	payments := make([]simPayment, 0, len(s.payments))
+	for _, p := range s.payments {
+		payments = append(payments, p)
+	}
+	s.mu.Unlock()
+	sort.Slice(payments, func(i, j int) bool {
+		return payments[i].Notification.PaidAt.Before(payments[j].Notification.PaidAt)
+	})
+	return c.JSON(fiber.Map{"data": payments})
+}
+
+// notifyAgain sends the notification of a payment once more, the gateway is
+// expected to answer it like the first time.
+func (s *paygwSim) notifyAgain(c *fiber.Ctx) error {
+	s.mu.Lock()
+	payment, ok := s.payments[c.Params("reference")]
+	s.mu.Unlock()
+	if !ok {
+		return errorResponse(c, fiber.StatusNotFound, "Pembayaran tidak ditemukan")
+	}
+	payment = s.notify(c.UserContext(), payment)
+	return c.JSON(fiber.Map{"data": payment})
+}
+
+// lookup finds the bill of the virtual account in the path, the status and
+// message tell why it can not be paid otherwise.
+func (s *paygwSim) lookup(c *fiber.Ctx) (schemas.PaymentProvider, schemas.Transaction, int, string) {
+	var trx schemas.Transaction
+	provider, ok := schemas.PaymentProviderOf(c.Params("provider"))
+	if !ok {
+		return provider, trx, fiber.StatusNotFound, "Metode pembayaran tidak ditemukan"
+	}
+	virtualAccount := c.Params("virtual_account")
+	if !provider.ValidVirtualAccount(virtualAccount) {
+		return provider, trx, fiber.StatusBadRequest, "Virtual account tidak valid"
+	}
+	ctx, cancel := context.WithTimeout(c.UserContext(), simTimeout)
+	defer cancel()
+	trx, err := s.rpc.Transaction().DetailBilling(ctx, uuid.NewString(), schemas.Transaction{
+		PaymentMethod:  provider.Code,
+		VirtualAccount: virtualAccount,
+	})
+	if schemas.IsNotFound(err) {
+		return provider, trx, fiber.StatusNotFound, "Tagihan tidak ditemukan"
+	}
+	if err != nil {
+		log.Println(err)
+		return provider, trx, fiber.StatusBadGateway, "Tagihan tidak dapat diperiksa"
+	}
+	return provider, trx, fiber.StatusOK, ""
+}
+
+// notify sends the signed notification of the payment to the gateway until
+// it is handled, and records the last answer.
+func (s *paygwSim) notify(ctx context.Context, payment simPayment) simPayment {
+	body, _ := json.Marshal(payment.Notification)
+	url := fmt.Sprintf("%s/webhook/payment/%s", s.gatewayUrl, payment.Provider)
+	for attempt := 1; attempt <= NOTIFICATION_ATTEMPTS; attempt++ {
+		status, respBody, err := s.send(ctx, url, body)
+		if err != nil {
+			log.Printf("Failed to notify %s of %s: %s", url, payment.Notification.Reference, err)
+			status, respBody = 0, nil
+		}
+		payment.NotifiedStatus = status
+		payment.NotifiedBody = respBody
+		// the gateway answers 4xx for notifications it will never accept
+		if status != 0 && status < 500 {
+			break
+		}
+		if attempt < NOTIFICATION_ATTEMPTS {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	s.mu.Lock()
+	s.payments[payment.Notification.Reference] = payment
+	s.mu.Unlock()
+	return payment
+}
+
+func (s *paygwSim) send(ctx context.Context, url string, body []byte) (int, json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(schemas.HEADER_NOTIFICATION_TIMESTAMP, fmt.Sprint(now.Unix()))
+	req.Header.Set(schemas.HEADER_NOTIFICATION_SIGNATURE, schemas.SignPaymentNotification(s.secret, now, body))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	if !json.Valid(respBody) {
+		respBody, _ = json.Marshal(string(respBody))
+	}
+	return resp.StatusCode, respBody, nil
+}
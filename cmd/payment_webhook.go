@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+// paymentWebhook flags the bills paid as notified by the payment providers at
+// /webhook/payment/:provider. The notifications are signed with the secret
+// in PAYMENT_WEBHOOK_SECRET and are idempotent on their reference, the
+// provider retries the ones not answered with 200.
+func paymentWebhook(rpc schemas.Rpc) func(c *fiber.Ctx) error {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		log.Println("PAYMENT_WEBHOOK_SECRET is not set, payment notifications are refused")
+	}
+	return func(c *fiber.Ctx) error {
+		if secret == "" {
+			return errorResponse(c, fiber.StatusServiceUnavailable, "Webhook pembayaran belum dikonfigurasi")
+		}
+		provider, ok := schemas.PaymentProviderOf(c.Params("provider"))
+		if !ok {
+			return errorResponse(c, fiber.StatusNotFound, "Metode pembayaran tidak ditemukan")
+		}
+		body := c.Body()
+		timestamp := c.Get(schemas.HEADER_NOTIFICATION_TIMESTAMP)
+		signature := c.Get(schemas.HEADER_NOTIFICATION_SIGNATURE)
+		if !schemas.VerifyPaymentNotification(secret, timestamp, signature, body, time.Now()) {
+			return errorResponse(c, fiber.StatusUnauthorized, "Signature tidak valid")
+		}
+		var notification schemas.PaymentNotification
+		if err := json.Unmarshal(body, &notification); err != nil {
+			return errorResponse(c, fiber.StatusBadRequest, "Format notifikasi tidak valid")
+		}
+		if notification.VirtualAccount == "" || notification.Reference == "" || notification.PaidAt.IsZero() {
+			return errorResponse(c, fiber.StatusBadRequest, "virtual_account, reference dan paid_at harus diisi")
+		}
+		if notification.Amount <= 0 {
+			return errorResponse(c, fiber.StatusBadRequest, "amount harus lebih dari 0")
+		}
+		if !provider.ValidVirtualAccount(notification.VirtualAccount) {
+			return errorResponse(c, fiber.StatusBadRequest, "Virtual account tidak valid")
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		correlationId := uuid.NewString()
+		c.Set("correlation-id", correlationId)
+		log.Printf("Payment notification %s %s for %s with correlation-id: %s", provider.Code, notification.Reference, notification.VirtualAccount, correlationId)
+
+		trx, err := rpc.Transaction().DetailBilling(ctx, correlationId, schemas.Transaction{
+			PaymentMethod:  provider.Code,
+			VirtualAccount: notification.VirtualAccount,
+		})
+		if schemas.IsNotFound(err) {
+			return errorResponse(c, fiber.StatusNotFound, "Tagihan tidak ditemukan")
+		}
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		pf, err := rpc.Transaction().ProcessPayment(ctx, correlationId, schemas.PaymentFlag{
+			Transaction: trx,
+			Payment: schemas.TransactionPayment{
+				Reference:       notification.Reference,
+				Amount:          notification.Amount,
+				PaymentDatetime: notification.PaidAt,
+			},
+		})
+		if alreadyReceived(err) {
+			return c.JSON(fiber.Map{
+				"data": fiber.Map{"transaction_uuid": trx.Uuid, "reference": notification.Reference, "status": trx.Status, "paid": trx.Paid},
+			})
+		}
+		if schemas.IsDuplicate(err) {
+			return errorResponse(c, fiber.StatusConflict, "Tagihan sudah terbayar")
+		}
+		if schemas.IsExpired(err) {
+			return errorResponse(c, fiber.StatusGone, "Tagihan sudah kedaluwarsa")
+		}
+		if err != nil {
+			return webhookError(c, err)
+		}
+		return c.JSON(fiber.Map{
+			"data": fiber.Map{
+				"transaction_uuid": trx.Uuid,
+				"reference":        pf.Payment.Reference,
+				"status":           pf.Transaction.Status,
+				"paid":             pf.Transaction.Paid,
+				"credit":           pf.Transaction.Credit,
+			},
+		})
+	}
+}
+
+// alreadyReceived tells whether err is replied for a notification whose
+// payment was recorded by an earlier delivery.
+func alreadyReceived(err error) bool {
+	var rErr *schemas.RPCError
+	return errors.As(err, &rErr) && rErr.Code == schemas.CODE_DUPLICATE && rErr.Message == schemas.PAYMENT_ALREADY_RECEIVED
+}
+
+// webhookError answers with an error status so the provider retries the
+// notification later.
+func webhookError(c *fiber.Ctx, err error) error {
+	log.Println(err)
+	if schemas.IsTimeout(err) {
+		return errorResponse(c, fiber.StatusGatewayTimeout, "Layanan sedang tidak merespon, silahkan coba lagi")
+	}
+	return errorResponse(c, fiber.StatusInternalServerError, "Internal server error")
+}
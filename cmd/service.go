@@ -1,12 +1,27 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"wahyuade.com/simple-e-commerce/schemas"
+	"wahyuade.com/simple-e-commerce/services"
 )
 
+var transport string
+var grpcAddr string
+var workers int
+
+// shutdownTimeout is how long the requests being handled are given to finish
+// once a termination signal is received.
+var shutdownTimeout time.Duration
+
 func init() {
 	runStartServiceCmd.AddCommand(
 		runUserServiceCmd,
@@ -14,6 +29,10 @@ func init() {
 		runTransactionServiceCmd,
 		runOrderServiceCmd,
 	)
+	runStartServiceCmd.PersistentFlags().StringVar(&transport, "transport", schemas.TRANSPORT_AMQP, "transport used to serve the requests (amqp|grpc)")
+	runStartServiceCmd.PersistentFlags().IntVar(&workers, "workers", 1, "number of concurrent consumers per queue")
+	runStartServiceCmd.PersistentFlags().DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "time given to the requests being handled when stopping")
+	runStartServiceCmd.PersistentFlags().StringVar(&grpcAddr, "grpc-addr", "", "address to listen on when using the grpc transport")
 }
 
 var runStartServiceCmd = &cobra.Command{
@@ -30,9 +49,47 @@ var runStartServiceCmd = &cobra.Command{
 		fmt.Println("  product       Run the product service")
 		fmt.Println("  transaction   Run the transaction service")
 		fmt.Println("  order         Run the order service")
+		fmt.Println("  paygw-sim     Run the payment gateway simulator")
 		fmt.Println()
 		fmt.Println(`Use " start --help" for more information about the start command.`)
 		fmt.Println()
 		os.Exit(1)
 	},
 }
+
+// newService bootstraps the service over the transport given by --transport.
+// The grpc transport listens on --grpc-addr, or the port of the module when
+// it is not set.
+func newService(defaultAddr string) services.Service {
+	switch transport {
+	case schemas.TRANSPORT_AMQP:
+		return services.New(workers)
+	case schemas.TRANSPORT_GRPC:
+		addr := grpcAddr
+		if addr == "" {
+			addr = defaultAddr
+		}
+		return services.NewGrpc(addr)
+	default:
+		fmt.Printf("Error: unknown transport %s\n", transport)
+		os.Exit(1)
+		return nil
+	}
+}
+
+// waitForShutdown blocks until SIGINT or SIGTERM is received and shuts the
+// service down. A second signal kills the process right away.
+func waitForShutdown(service services.Service) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+
+	log.Println("Shutting down...")
+	timeout, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := service.Shutdown(timeout); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+	log.Println("Service stopped")
+}
@@ -1,9 +1,6 @@
 package cmd
 
 import (
-	"bufio"
-	"os"
-
 	"github.com/spf13/cobra"
 	"wahyuade.com/simple-e-commerce/services"
 )
@@ -12,10 +9,8 @@ var runTransactionServiceCmd = &cobra.Command{
 	Use:   "transaction",
 	Short: "Run the transaction service",
 	Run: func(cmd *cobra.Command, args []string) {
-		service := services.New()
+		service := newService(":7003")
 		service.Bootstrap(services.TransactionService{})
-
-		reader := bufio.NewReader(os.Stdin)
-		reader.ReadString('\n')
+		waitForShutdown(service)
 	},
 }
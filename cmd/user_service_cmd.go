@@ -1,9 +1,6 @@
 package cmd
 
 import (
-	"bufio"
-	"os"
-
 	"github.com/spf13/cobra"
 	"wahyuade.com/simple-e-commerce/services"
 )
@@ -12,10 +9,8 @@ var runUserServiceCmd = &cobra.Command{
 	Use:   "user",
 	Short: "Run the user service",
 	Run: func(cmd *cobra.Command, args []string) {
-		service := services.New()
+		service := newService(":7001")
 		service.Bootstrap(services.UserService{})
-
-		reader := bufio.NewReader(os.Stdin)
-		reader.ReadString('\n')
+		waitForShutdown(service)
 	},
 }
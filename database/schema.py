@@ -1,6 +1,6 @@
 from operator import index
 from sqlalchemy.ext.declarative import declarative_base
-from sqlalchemy.dialects.postgresql import UUID
+from sqlalchemy.dialects.postgresql import UUID, ARRAY
 from sqlalchemy import (
     MetaData,
     Column,
@@ -39,6 +39,26 @@ class Product(Base):
   stock = Column(Integer, nullable=False)
   price = Column(Integer, nullable=False)
 
+class ProductReservation(Base):
+  __tablename__ = 'product_reservation'
+
+  transaction_uuid = Column(UUID, primary_key=True)
+  product_uuid = Column(UUID, primary_key=True)
+  qty = Column(Integer, nullable=False)
+  status = Column(Text, nullable=False)
+  expired = Column(DateTime, nullable=False, index=True)
+  updated = Column(DateTime, nullable=False)
+
+class ProductStockReturn(Base):
+  __tablename__ = 'product_stock_return'
+
+  return_uuid = Column(UUID, primary_key=True)
+  product_uuid = Column(UUID, primary_key=True)
+  transaction_uuid = Column(UUID, nullable=True)
+  order_uuid = Column(UUID, nullable=True, index=True)
+  qty = Column(Integer, nullable=False)
+  created = Column(DateTime, nullable=False)
+
 class Transaction(Base):
   __tablename__ = 'transaction'
 
@@ -54,6 +74,13 @@ class Transaction(Base):
   expired = Column(DateTime, nullable=False)
   payment_method = Column(Text, nullable=False)
   virtual_account = Column(Text, nullable=False)
+  subtotal = Column(Integer, nullable=False, server_default='0')
+  discount = Column(Integer, nullable=False, server_default='0')
+  tax = Column(Integer, nullable=False, server_default='0')
+  fee = Column(Integer, nullable=False, server_default='0')
+  breakdown = Column(Text)
+  order_uuid = Column(UUID, nullable=True)
+  paid = Column(Integer, nullable=False, server_default='0')
 
 class TransactionCart(Base):
   __tablename__ = 'transaction_cart'
@@ -70,9 +97,67 @@ class TransactionPayment(Base):
   uuid = Column(UUID, primary_key=True)
   reference = Column(Text, nullable=False)
   amount = Column(Integer, nullable=False)
-  transaction_uuid = Column(UUID, nullable=False)
+  transaction_uuid = Column(UUID, nullable=False, index=True)
   payment_datetime = Column(DateTime, nullable=False)
 
+class TransactionRefund(Base):
+  __tablename__ = 'transaction_refund'
+
+  uuid = Column(UUID, primary_key=True)
+  transaction_uuid = Column(UUID, nullable=False, index=True)
+  amount = Column(Integer, nullable=False)
+  reason = Column(Text, nullable=False)
+  created = Column(DateTime, nullable=False)
+
+class TransactionRefundItem(Base):
+  __tablename__ = 'transaction_refund_item'
+
+  refund_uuid = Column(UUID, primary_key=True)
+  product_uuid = Column(UUID, primary_key=True)
+  qty = Column(Integer, nullable=False)
+
+class CustomerCredit(Base):
+  __tablename__ = 'customer_credit'
+
+  uuid = Column(UUID, primary_key=True)
+  user_uuid = Column(UUID, nullable=False, index=True)
+  transaction_uuid = Column(UUID, nullable=False, index=True)
+  reference = Column(Text, nullable=True)
+  amount = Column(Integer, nullable=False)
+  reason = Column(Text, nullable=False)
+  created = Column(DateTime, nullable=False)
+
+class Voucher(Base):
+  __tablename__ = 'voucher'
+
+  code = Column(Text, primary_key=True)
+  description = Column(Text, nullable=False)
+  type = Column(Text, nullable=False)
+  value = Column(Integer, nullable=False)
+  max_discount = Column(Integer, nullable=False, server_default='0')
+  min_spend = Column(Integer, nullable=False, server_default='0')
+  usage_limit = Column(Integer, nullable=False, server_default='0')
+  valid_from = Column(DateTime, nullable=False)
+  valid_until = Column(DateTime, nullable=False)
+  product_uuids = Column(ARRAY(UUID), nullable=True)
+
+class VoucherRedemption(Base):
+  __tablename__ = 'voucher_redemption'
+
+  transaction_uuid = Column(UUID, primary_key=True)
+  voucher_code = Column(Text, nullable=False, index=True)
+  user_uuid = Column(UUID, nullable=False, index=True)
+  amount = Column(Integer, nullable=False)
+  status = Column(Text, nullable=False)
+  created = Column(DateTime, nullable=False)
+  updated = Column(DateTime, nullable=False)
+
+class CartVoucher(Base):
+  __tablename__ = 'cart_voucher'
+
+  user_uuid = Column(UUID, primary_key=True)
+  voucher_code = Column(Text, nullable=False)
+
 
 class Order(Base):
   __tablename__ = 'order'
@@ -80,6 +165,10 @@ class Order(Base):
   uuid = Column(UUID, primary_key=True)
   user_uuid = Column(UUID, nullable=False, index=True)
   amount = Column(Integer, nullable=False)
+  status = Column(Text, nullable=False, server_default='CREATED')
+  refund_status = Column(Text, nullable=False, server_default='')
+  transaction_uuid = Column(UUID, nullable=True, unique=True)
+  payment_reference = Column(Text, nullable=True)
   created = Column(DateTime, nullable=False)
 
 class OrderItem(Base):
@@ -90,4 +179,44 @@ class OrderItem(Base):
   name = Column(Text, nullable=False)
   description = Column(Text, nullable=False)
   price = Column(Integer, nullable=False)
-  qty = Column(Integer, nullable=False)
\ No newline at end of file
+  qty = Column(Integer, nullable=False)
+
+class OrderStatusHistory(Base):
+  __tablename__ = 'order_status_history'
+
+  uuid = Column(UUID, primary_key=True)
+  order_uuid = Column(UUID, nullable=False, index=True)
+  from_status = Column(Text, nullable=True)
+  status = Column(Text, nullable=False)
+  note = Column(Text, nullable=False, server_default='')
+  created = Column(DateTime, nullable=False)
+
+class Saga(Base):
+  __tablename__ = 'saga'
+
+  uuid = Column(UUID, primary_key=True)
+  name = Column(Text, nullable=False)
+  status = Column(Text, nullable=False, index=True)
+  data = Column(Text, nullable=False)
+  error = Column(Text, nullable=True)
+  created = Column(DateTime, nullable=False)
+  updated = Column(DateTime, nullable=False)
+
+class SagaStep(Base):
+  __tablename__ = 'saga_step'
+
+  saga_uuid = Column(UUID, primary_key=True)
+  name = Column(Text, primary_key=True)
+  status = Column(Text, nullable=False)
+  error = Column(Text, nullable=True)
+  updated = Column(DateTime, nullable=False)
+
+class Outbox(Base):
+  __tablename__ = 'outbox'
+
+  uuid = Column(UUID, primary_key=True)
+  source = Column(Text, nullable=False, index=True)
+  event = Column(Text, nullable=False)
+  payload = Column(Text, nullable=False)
+  created = Column(DateTime, nullable=False)
+  sent = Column(DateTime, nullable=True)
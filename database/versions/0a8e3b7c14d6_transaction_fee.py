@@ -0,0 +1,28 @@
+"""transaction fee
+
+Revision ID: 0a8e3b7c14d6
+Revises: f6b1d83e5a29
+Create Date: 2026-10-18 18:12:33.906215
+
+"""
+from alembic import op
+import sqlalchemy as sa
+
+
+# revision identifiers, used by Alembic.
+revision = '0a8e3b7c14d6'
+down_revision = 'f6b1d83e5a29'
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.add_column('transaction', sa.Column('fee', sa.Integer(), server_default='0', nullable=False))
+    # ### end Alembic commands ###
+
+
+def downgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.drop_column('transaction', 'fee')
+    # ### end Alembic commands ###
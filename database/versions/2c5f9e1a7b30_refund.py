@@ -0,0 +1,57 @@
+"""refund
+
+Revision ID: 2c5f9e1a7b30
+Revises: 0a8e3b7c14d6
+Create Date: 2026-10-18 19:34:18.257041
+
+"""
+from alembic import op
+import sqlalchemy as sa
+from sqlalchemy.dialects import postgresql
+
+# revision identifiers, used by Alembic.
+revision = '2c5f9e1a7b30'
+down_revision = '0a8e3b7c14d6'
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.create_table('transaction_refund',
+    sa.Column('uuid', postgresql.UUID(), nullable=False),
+    sa.Column('transaction_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('amount', sa.Integer(), nullable=False),
+    sa.Column('reason', sa.Text(), nullable=False),
+    sa.Column('created', sa.DateTime(), nullable=False),
+    sa.PrimaryKeyConstraint('uuid', name=op.f('pk_transaction_refund'))
+    )
+    op.create_index(op.f('ix_transaction_refund_transaction_uuid'), 'transaction_refund', ['transaction_uuid'], unique=False)
+    op.create_table('transaction_refund_item',
+    sa.Column('refund_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('product_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('qty', sa.Integer(), nullable=False),
+    sa.PrimaryKeyConstraint('refund_uuid', 'product_uuid', name=op.f('pk_transaction_refund_item'))
+    )
+    op.create_table('product_stock_return',
+    sa.Column('refund_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('product_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('transaction_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('qty', sa.Integer(), nullable=False),
+    sa.Column('created', sa.DateTime(), nullable=False),
+    sa.PrimaryKeyConstraint('refund_uuid', 'product_uuid', name=op.f('pk_product_stock_return'))
+    )
+    op.add_column('transaction', sa.Column('order_uuid', postgresql.UUID(), nullable=True))
+    op.add_column('order', sa.Column('status', sa.Text(), server_default='CREATED', nullable=False))
+    # ### end Alembic commands ###
+
+
+def downgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.drop_column('order', 'status')
+    op.drop_column('transaction', 'order_uuid')
+    op.drop_table('product_stock_return')
+    op.drop_table('transaction_refund_item')
+    op.drop_index(op.f('ix_transaction_refund_transaction_uuid'), table_name='transaction_refund')
+    op.drop_table('transaction_refund')
+    # ### end Alembic commands ###
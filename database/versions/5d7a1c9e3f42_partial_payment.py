@@ -0,0 +1,48 @@
+"""partial payment
+
+Revision ID: 5d7a1c9e3f42
+Revises: 2c5f9e1a7b30
+Create Date: 2026-10-18 21:12:40.518736
+
+"""
+from alembic import op
+import sqlalchemy as sa
+from sqlalchemy.dialects import postgresql
+
+# revision identifiers, used by Alembic.
+revision = '5d7a1c9e3f42'
+down_revision = '2c5f9e1a7b30'
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.create_table('customer_credit',
+    sa.Column('uuid', postgresql.UUID(), nullable=False),
+    sa.Column('user_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('transaction_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('reference', sa.Text(), nullable=True),
+    sa.Column('amount', sa.Integer(), nullable=False),
+    sa.Column('reason', sa.Text(), nullable=False),
+    sa.Column('created', sa.DateTime(), nullable=False),
+    sa.PrimaryKeyConstraint('uuid', name=op.f('pk_customer_credit'))
+    )
+    op.create_index(op.f('ix_customer_credit_transaction_uuid'), 'customer_credit', ['transaction_uuid'], unique=False)
+    op.create_index(op.f('ix_customer_credit_user_uuid'), 'customer_credit', ['user_uuid'], unique=False)
+    op.add_column('transaction', sa.Column('paid', sa.Integer(), server_default='0', nullable=False))
+    op.create_index(op.f('ix_transaction_payment_transaction_uuid'), 'transaction_payment', ['transaction_uuid'], unique=False)
+    # ### end Alembic commands ###
+    op.execute('''UPDATE "transaction" t SET paid = p.amount FROM (
+        SELECT transaction_uuid, SUM(amount) AS amount FROM "transaction_payment" GROUP BY transaction_uuid
+    ) p WHERE p.transaction_uuid = t.uuid''')
+
+
+def downgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.drop_index(op.f('ix_transaction_payment_transaction_uuid'), table_name='transaction_payment')
+    op.drop_column('transaction', 'paid')
+    op.drop_index(op.f('ix_customer_credit_user_uuid'), table_name='customer_credit')
+    op.drop_index(op.f('ix_customer_credit_transaction_uuid'), table_name='customer_credit')
+    op.drop_table('customer_credit')
+    # ### end Alembic commands ###
@@ -0,0 +1,47 @@
+"""order status
+
+Revision ID: 7b3e9d2a6c15
+Revises: 5d7a1c9e3f42
+Create Date: 2026-10-18 22:05:11.873402
+
+"""
+from alembic import op
+import sqlalchemy as sa
+from sqlalchemy.dialects import postgresql
+
+# revision identifiers, used by Alembic.
+revision = '7b3e9d2a6c15'
+down_revision = '5d7a1c9e3f42'
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.create_table('order_status_history',
+    sa.Column('uuid', postgresql.UUID(), nullable=False),
+    sa.Column('order_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('from_status', sa.Text(), nullable=True),
+    sa.Column('status', sa.Text(), nullable=False),
+    sa.Column('note', sa.Text(), server_default='', nullable=False),
+    sa.Column('created', sa.DateTime(), nullable=False),
+    sa.PrimaryKeyConstraint('uuid', name=op.f('pk_order_status_history'))
+    )
+    op.create_index(op.f('ix_order_status_history_order_uuid'), 'order_status_history', ['order_uuid'], unique=False)
+    op.add_column('order', sa.Column('refund_status', sa.Text(), server_default='', nullable=False))
+    # ### end Alembic commands ###
+    # the refunds were kept in the status before the order had a lifecycle
+    op.execute('''UPDATE "order" SET refund_status = status, status = 'CREATED'
+        WHERE status IN ('PARTIALLY_REFUNDED', 'REFUNDED')''')
+    # the creation of the existing orders, an order has a single one
+    op.execute('''INSERT INTO "order_status_history" (uuid, order_uuid, from_status, status, created)
+        SELECT uuid, uuid, NULL, 'CREATED', created FROM "order"''')
+
+
+def downgrade():
+    op.execute('''UPDATE "order" SET status = refund_status WHERE refund_status <> \'\'''')
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.drop_column('order', 'refund_status')
+    op.drop_index(op.f('ix_order_status_history_order_uuid'), table_name='order_status_history')
+    op.drop_table('order_status_history')
+    # ### end Alembic commands ###
@@ -0,0 +1,48 @@
+"""saga
+
+Revision ID: 8f2d6c1b9a47
+Revises: 3c4b74d1a51a
+Create Date: 2026-10-18 09:12:31.402118
+
+"""
+from alembic import op
+import sqlalchemy as sa
+from sqlalchemy.dialects import postgresql
+
+# revision identifiers, used by Alembic.
+revision = '8f2d6c1b9a47'
+down_revision = '3c4b74d1a51a'
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.create_table('saga',
+    sa.Column('uuid', postgresql.UUID(), nullable=False),
+    sa.Column('name', sa.Text(), nullable=False),
+    sa.Column('status', sa.Text(), nullable=False),
+    sa.Column('data', sa.Text(), nullable=False),
+    sa.Column('error', sa.Text(), nullable=True),
+    sa.Column('created', sa.DateTime(), nullable=False),
+    sa.Column('updated', sa.DateTime(), nullable=False),
+    sa.PrimaryKeyConstraint('uuid', name=op.f('pk_saga'))
+    )
+    op.create_index(op.f('ix_saga_status'), 'saga', ['status'], unique=False)
+    op.create_table('saga_step',
+    sa.Column('saga_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('name', sa.Text(), nullable=False),
+    sa.Column('status', sa.Text(), nullable=False),
+    sa.Column('error', sa.Text(), nullable=True),
+    sa.Column('updated', sa.DateTime(), nullable=False),
+    sa.PrimaryKeyConstraint('saga_uuid', 'name', name=op.f('pk_saga_step'))
+    )
+    # ### end Alembic commands ###
+
+
+def downgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.drop_table('saga_step')
+    op.drop_index(op.f('ix_saga_status'), table_name='saga')
+    op.drop_table('saga')
+    # ### end Alembic commands ###
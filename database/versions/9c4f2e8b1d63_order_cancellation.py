@@ -0,0 +1,41 @@
+"""order cancellation
+
+Revision ID: 9c4f2e8b1d63
+Revises: 7b3e9d2a6c15
+Create Date: 2026-10-18 22:48:36.104927
+
+"""
+from alembic import op
+import sqlalchemy as sa
+from sqlalchemy.dialects import postgresql
+
+# revision identifiers, used by Alembic.
+revision = '9c4f2e8b1d63'
+down_revision = '7b3e9d2a6c15'
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.alter_column('product_stock_return', 'refund_uuid', new_column_name='return_uuid')
+    op.alter_column('product_stock_return', 'transaction_uuid',
+               existing_type=postgresql.UUID(),
+               nullable=True)
+    op.add_column('product_stock_return', sa.Column('order_uuid', postgresql.UUID(), nullable=True))
+    op.create_index(op.f('ix_product_stock_return_order_uuid'), 'product_stock_return', ['order_uuid'], unique=False)
+    # ### end Alembic commands ###
+    op.execute('''UPDATE "product_stock_return" r SET order_uuid = t.order_uuid
+        FROM "transaction" t WHERE t.uuid = r.transaction_uuid''')
+
+
+def downgrade():
+    op.execute('''DELETE FROM "product_stock_return" WHERE transaction_uuid IS NULL''')
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.drop_index(op.f('ix_product_stock_return_order_uuid'), table_name='product_stock_return')
+    op.drop_column('product_stock_return', 'order_uuid')
+    op.alter_column('product_stock_return', 'transaction_uuid',
+               existing_type=postgresql.UUID(),
+               nullable=False)
+    op.alter_column('product_stock_return', 'return_uuid', new_column_name='refund_uuid')
+    # ### end Alembic commands ###
@@ -0,0 +1,38 @@
+"""outbox
+
+Revision ID: b51e0a7d3c28
+Revises: 8f2d6c1b9a47
+Create Date: 2026-10-18 11:40:05.918264
+
+"""
+from alembic import op
+import sqlalchemy as sa
+from sqlalchemy.dialects import postgresql
+
+# revision identifiers, used by Alembic.
+revision = 'b51e0a7d3c28'
+down_revision = '8f2d6c1b9a47'
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.create_table('outbox',
+    sa.Column('uuid', postgresql.UUID(), nullable=False),
+    sa.Column('source', sa.Text(), nullable=False),
+    sa.Column('event', sa.Text(), nullable=False),
+    sa.Column('payload', sa.Text(), nullable=False),
+    sa.Column('created', sa.DateTime(), nullable=False),
+    sa.Column('sent', sa.DateTime(), nullable=True),
+    sa.PrimaryKeyConstraint('uuid', name=op.f('pk_outbox'))
+    )
+    op.create_index(op.f('ix_outbox_source'), 'outbox', ['source'], unique=False)
+    # ### end Alembic commands ###
+
+
+def downgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.drop_index(op.f('ix_outbox_source'), table_name='outbox')
+    op.drop_table('outbox')
+    # ### end Alembic commands ###
@@ -0,0 +1,37 @@
+"""order transaction
+
+Revision ID: b8e1d4a7f290
+Revises: 9c4f2e8b1d63
+Create Date: 2026-10-18 23:21:57.640318
+
+"""
+from alembic import op
+import sqlalchemy as sa
+from sqlalchemy.dialects import postgresql
+
+# revision identifiers, used by Alembic.
+revision = 'b8e1d4a7f290'
+down_revision = '9c4f2e8b1d63'
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.add_column('order', sa.Column('transaction_uuid', postgresql.UUID(), nullable=True))
+    op.add_column('order', sa.Column('payment_reference', sa.Text(), nullable=True))
+    op.create_unique_constraint(op.f('uq_order_transaction_uuid'), 'order', ['transaction_uuid'])
+    # ### end Alembic commands ###
+    # the bill paid for an existing order, with its last payment
+    op.execute('''UPDATE "order" o SET transaction_uuid = t.uuid, payment_reference = (
+            SELECT p.reference FROM "transaction_payment" p WHERE p.transaction_uuid = t.uuid
+            ORDER BY p.payment_datetime DESC LIMIT 1
+        ) FROM "transaction" t WHERE t.order_uuid = o.uuid''')
+
+
+def downgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.drop_constraint(op.f('uq_order_transaction_uuid'), 'order', type_='unique')
+    op.drop_column('order', 'payment_reference')
+    op.drop_column('order', 'transaction_uuid')
+    # ### end Alembic commands ###
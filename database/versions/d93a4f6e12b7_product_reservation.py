@@ -0,0 +1,38 @@
+"""product reservation
+
+Revision ID: d93a4f6e12b7
+Revises: b51e0a7d3c28
+Create Date: 2026-10-18 14:02:47.551930
+
+"""
+from alembic import op
+import sqlalchemy as sa
+from sqlalchemy.dialects import postgresql
+
+# revision identifiers, used by Alembic.
+revision = 'd93a4f6e12b7'
+down_revision = 'b51e0a7d3c28'
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.create_table('product_reservation',
+    sa.Column('transaction_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('product_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('qty', sa.Integer(), nullable=False),
+    sa.Column('status', sa.Text(), nullable=False),
+    sa.Column('expired', sa.DateTime(), nullable=False),
+    sa.Column('updated', sa.DateTime(), nullable=False),
+    sa.PrimaryKeyConstraint('transaction_uuid', 'product_uuid', name=op.f('pk_product_reservation'))
+    )
+    op.create_index(op.f('ix_product_reservation_expired'), 'product_reservation', ['expired'], unique=False)
+    # ### end Alembic commands ###
+
+
+def downgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.drop_index(op.f('ix_product_reservation_expired'), table_name='product_reservation')
+    op.drop_table('product_reservation')
+    # ### end Alembic commands ###
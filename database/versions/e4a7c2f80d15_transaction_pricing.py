@@ -0,0 +1,34 @@
+"""transaction pricing
+
+Revision ID: e4a7c2f80d15
+Revises: d93a4f6e12b7
+Create Date: 2026-10-18 16:21:09.384512
+
+"""
+from alembic import op
+import sqlalchemy as sa
+
+
+# revision identifiers, used by Alembic.
+revision = 'e4a7c2f80d15'
+down_revision = 'd93a4f6e12b7'
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.add_column('transaction', sa.Column('subtotal', sa.Integer(), server_default='0', nullable=False))
+    op.add_column('transaction', sa.Column('discount', sa.Integer(), server_default='0', nullable=False))
+    op.add_column('transaction', sa.Column('tax', sa.Integer(), server_default='0', nullable=False))
+    op.add_column('transaction', sa.Column('breakdown', sa.Text(), nullable=True))
+    # ### end Alembic commands ###
+
+
+def downgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.drop_column('transaction', 'breakdown')
+    op.drop_column('transaction', 'tax')
+    op.drop_column('transaction', 'discount')
+    op.drop_column('transaction', 'subtotal')
+    # ### end Alembic commands ###
@@ -0,0 +1,61 @@
+"""voucher
+
+Revision ID: f6b1d83e5a29
+Revises: e4a7c2f80d15
+Create Date: 2026-10-18 17:05:42.118730
+
+"""
+from alembic import op
+import sqlalchemy as sa
+from sqlalchemy.dialects import postgresql
+
+# revision identifiers, used by Alembic.
+revision = 'f6b1d83e5a29'
+down_revision = 'e4a7c2f80d15'
+branch_labels = None
+depends_on = None
+
+
+def upgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.create_table('voucher',
+    sa.Column('code', sa.Text(), nullable=False),
+    sa.Column('description', sa.Text(), nullable=False),
+    sa.Column('type', sa.Text(), nullable=False),
+    sa.Column('value', sa.Integer(), nullable=False),
+    sa.Column('max_discount', sa.Integer(), server_default='0', nullable=False),
+    sa.Column('min_spend', sa.Integer(), server_default='0', nullable=False),
+    sa.Column('usage_limit', sa.Integer(), server_default='0', nullable=False),
+    sa.Column('valid_from', sa.DateTime(), nullable=False),
+    sa.Column('valid_until', sa.DateTime(), nullable=False),
+    sa.Column('product_uuids', postgresql.ARRAY(postgresql.UUID()), nullable=True),
+    sa.PrimaryKeyConstraint('code', name=op.f('pk_voucher'))
+    )
+    op.create_table('voucher_redemption',
+    sa.Column('transaction_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('voucher_code', sa.Text(), nullable=False),
+    sa.Column('user_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('amount', sa.Integer(), nullable=False),
+    sa.Column('status', sa.Text(), nullable=False),
+    sa.Column('created', sa.DateTime(), nullable=False),
+    sa.Column('updated', sa.DateTime(), nullable=False),
+    sa.PrimaryKeyConstraint('transaction_uuid', name=op.f('pk_voucher_redemption'))
+    )
+    op.create_index(op.f('ix_voucher_redemption_user_uuid'), 'voucher_redemption', ['user_uuid'], unique=False)
+    op.create_index(op.f('ix_voucher_redemption_voucher_code'), 'voucher_redemption', ['voucher_code'], unique=False)
+    op.create_table('cart_voucher',
+    sa.Column('user_uuid', postgresql.UUID(), nullable=False),
+    sa.Column('voucher_code', sa.Text(), nullable=False),
+    sa.PrimaryKeyConstraint('user_uuid', name=op.f('pk_cart_voucher'))
+    )
+    # ### end Alembic commands ###
+
+
+def downgrade():
+    # ### commands auto generated by Alembic - please adjust! ###
+    op.drop_table('cart_voucher')
+    op.drop_index(op.f('ix_voucher_redemption_voucher_code'), table_name='voucher_redemption')
+    op.drop_index(op.f('ix_voucher_redemption_user_uuid'), table_name='voucher_redemption')
+    op.drop_table('voucher_redemption')
+    op.drop_table('voucher')
+    # ### end Alembic commands ###
@@ -0,0 +1,465 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: transaction.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// TransactionServiceClient is the client API for TransactionService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type TransactionServiceClient interface {
+	CheckProductInCart(ctx context.Context, in *TransactionCart, opts ...grpc.CallOption) (*TransactionCart, error)
+	InsertProductToCart(ctx context.Context, in *TransactionCart, opts ...grpc.CallOption) (*TransactionCart, error)
+	ListCart(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*TransactionCartList, error)
+	UpdateCart(ctx context.Context, in *TransactionCart, opts ...grpc.CallOption) (*TransactionCart, error)
+	CreateBilling(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error)
+	ListTransactionItem(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*TransactionCartList, error)
+	DetailBilling(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error)
+	ProcessPayment(ctx context.Context, in *PaymentFlag, opts ...grpc.CallOption) (*PaymentFlag, error)
+	ApplyVoucher(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error)
+	CancelBilling(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error)
+	Refund(ctx context.Context, in *TransactionRefund, opts ...grpc.CallOption) (*TransactionRefund, error)
+}
+
+type transactionServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTransactionServiceClient(cc grpc.ClientConnInterface) TransactionServiceClient {
+	return &transactionServiceClient{cc}
+}
+
+func (c *transactionServiceClient) CheckProductInCart(ctx context.Context, in *TransactionCart, opts ...grpc.CallOption) (*TransactionCart, error) {
+	out := new(TransactionCart)
+	err := c.cc.Invoke(ctx, "/simple_ecommerce.TransactionService/CheckProductInCart", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionServiceClient) InsertProductToCart(ctx context.Context, in *TransactionCart, opts ...grpc.CallOption) (*TransactionCart, error) {
+	out := new(TransactionCart)
+	err := c.cc.Invoke(ctx, "/simple_ecommerce.TransactionService/InsertProductToCart", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionServiceClient) ListCart(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*TransactionCartList, error) {
+	out := new(TransactionCartList)
+	err := c.cc.Invoke(ctx, "/simple_ecommerce.TransactionService/ListCart", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionServiceClient) UpdateCart(ctx context.Context, in *TransactionCart, opts ...grpc.CallOption) (*TransactionCart, error) {
+	out := new(TransactionCart)
+	err := c.cc.Invoke(ctx, "/simple_ecommerce.TransactionService/UpdateCart", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionServiceClient) CreateBilling(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, "/simple_ecommerce.TransactionService/CreateBilling", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionServiceClient) ListTransactionItem(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*TransactionCartList, error) {
+	out := new(TransactionCartList)
+	err := c.cc.Invoke(ctx, "/simple_ecommerce.TransactionService/ListTransactionItem", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionServiceClient) DetailBilling(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, "/simple_ecommerce.TransactionService/DetailBilling", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionServiceClient) ProcessPayment(ctx context.Context, in *PaymentFlag, opts ...grpc.CallOption) (*PaymentFlag, error) {
+	out := new(PaymentFlag)
+	err := c.cc.Invoke(ctx, "/simple_ecommerce.TransactionService/ProcessPayment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionServiceClient) ApplyVoucher(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, "/simple_ecommerce.TransactionService/ApplyVoucher", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionServiceClient) CancelBilling(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := c.cc.Invoke(ctx, "/simple_ecommerce.TransactionService/CancelBilling", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *transactionServiceClient) Refund(ctx context.Context, in *TransactionRefund, opts ...grpc.CallOption) (*TransactionRefund, error) {
+	out := new(TransactionRefund)
+	err := c.cc.Invoke(ctx, "/simple_ecommerce.TransactionService/Refund", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TransactionServiceServer is the server API for TransactionService service.
+// All implementations must embed UnimplementedTransactionServiceServer
+// for forward compatibility
+type TransactionServiceServer interface {
+	CheckProductInCart(context.Context, *TransactionCart) (*TransactionCart, error)
+	InsertProductToCart(context.Context, *TransactionCart) (*TransactionCart, error)
+	ListCart(context.Context, *UserRequest) (*TransactionCartList, error)
+	UpdateCart(context.Context, *TransactionCart) (*TransactionCart, error)
+	CreateBilling(context.Context, *Transaction) (*Transaction, error)
+	ListTransactionItem(context.Context, *TransactionRequest) (*TransactionCartList, error)
+	DetailBilling(context.Context, *Transaction) (*Transaction, error)
+	ProcessPayment(context.Context, *PaymentFlag) (*PaymentFlag, error)
+	ApplyVoucher(context.Context, *Transaction) (*Transaction, error)
+	CancelBilling(context.Context, *Transaction) (*Transaction, error)
+	Refund(context.Context, *TransactionRefund) (*TransactionRefund, error)
+	mustEmbedUnimplementedTransactionServiceServer()
+}
+
+// UnimplementedTransactionServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedTransactionServiceServer struct {
+}
+
+func (UnimplementedTransactionServiceServer) CheckProductInCart(context.Context, *TransactionCart) (*TransactionCart, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckProductInCart not implemented")
+}
+func (UnimplementedTransactionServiceServer) InsertProductToCart(context.Context, *TransactionCart) (*TransactionCart, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InsertProductToCart not implemented")
+}
+func (UnimplementedTransactionServiceServer) ListCart(context.Context, *UserRequest) (*TransactionCartList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCart not implemented")
+}
+func (UnimplementedTransactionServiceServer) UpdateCart(context.Context, *TransactionCart) (*TransactionCart, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCart not implemented")
+}
+func (UnimplementedTransactionServiceServer) CreateBilling(context.Context, *Transaction) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateBilling not implemented")
+}
+func (UnimplementedTransactionServiceServer) ListTransactionItem(context.Context, *TransactionRequest) (*TransactionCartList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTransactionItem not implemented")
+}
+func (UnimplementedTransactionServiceServer) DetailBilling(context.Context, *Transaction) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DetailBilling not implemented")
+}
+func (UnimplementedTransactionServiceServer) ProcessPayment(context.Context, *PaymentFlag) (*PaymentFlag, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ProcessPayment not implemented")
+}
+func (UnimplementedTransactionServiceServer) ApplyVoucher(context.Context, *Transaction) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApplyVoucher not implemented")
+}
+func (UnimplementedTransactionServiceServer) CancelBilling(context.Context, *Transaction) (*Transaction, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelBilling not implemented")
+}
+func (UnimplementedTransactionServiceServer) Refund(context.Context, *TransactionRefund) (*TransactionRefund, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Refund not implemented")
+}
+func (UnimplementedTransactionServiceServer) mustEmbedUnimplementedTransactionServiceServer() {}
+
+// UnsafeTransactionServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TransactionServiceServer will
+// result in compilation errors.
+type UnsafeTransactionServiceServer interface {
+	mustEmbedUnimplementedTransactionServiceServer()
+}
+
+func RegisterTransactionServiceServer(s grpc.ServiceRegistrar, srv TransactionServiceServer) {
+	s.RegisterService(&TransactionService_ServiceDesc, srv)
+}
+
+func _TransactionService_CheckProductInCart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionCart)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServiceServer).CheckProductInCart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simple_ecommerce.TransactionService/CheckProductInCart",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServiceServer).CheckProductInCart(ctx, req.(*TransactionCart))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransactionService_InsertProductToCart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionCart)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServiceServer).InsertProductToCart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simple_ecommerce.TransactionService/InsertProductToCart",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServiceServer).InsertProductToCart(ctx, req.(*TransactionCart))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransactionService_ListCart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServiceServer).ListCart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simple_ecommerce.TransactionService/ListCart",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServiceServer).ListCart(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransactionService_UpdateCart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionCart)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServiceServer).UpdateCart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simple_ecommerce.TransactionService/UpdateCart",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServiceServer).UpdateCart(ctx, req.(*TransactionCart))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransactionService_CreateBilling_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServiceServer).CreateBilling(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simple_ecommerce.TransactionService/CreateBilling",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServiceServer).CreateBilling(ctx, req.(*Transaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransactionService_ListTransactionItem_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServiceServer).ListTransactionItem(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simple_ecommerce.TransactionService/ListTransactionItem",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServiceServer).ListTransactionItem(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransactionService_DetailBilling_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServiceServer).DetailBilling(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simple_ecommerce.TransactionService/DetailBilling",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServiceServer).DetailBilling(ctx, req.(*Transaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransactionService_ProcessPayment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PaymentFlag)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServiceServer).ProcessPayment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simple_ecommerce.TransactionService/ProcessPayment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServiceServer).ProcessPayment(ctx, req.(*PaymentFlag))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransactionService_ApplyVoucher_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServiceServer).ApplyVoucher(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simple_ecommerce.TransactionService/ApplyVoucher",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServiceServer).ApplyVoucher(ctx, req.(*Transaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransactionService_CancelBilling_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServiceServer).CancelBilling(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simple_ecommerce.TransactionService/CancelBilling",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServiceServer).CancelBilling(ctx, req.(*Transaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TransactionService_Refund_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRefund)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TransactionServiceServer).Refund(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/simple_ecommerce.TransactionService/Refund",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TransactionServiceServer).Refund(ctx, req.(*TransactionRefund))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// TransactionService_ServiceDesc is the grpc.ServiceDesc for TransactionService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TransactionService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "simple_ecommerce.TransactionService",
+	HandlerType: (*TransactionServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CheckProductInCart",
+			Handler:    _TransactionService_CheckProductInCart_Handler,
+		},
+		{
+			MethodName: "InsertProductToCart",
+			Handler:    _TransactionService_InsertProductToCart_Handler,
+		},
+		{
+			MethodName: "ListCart",
+			Handler:    _TransactionService_ListCart_Handler,
+		},
+		{
+			MethodName: "UpdateCart",
+			Handler:    _TransactionService_UpdateCart_Handler,
+		},
+		{
+			MethodName: "CreateBilling",
+			Handler:    _TransactionService_CreateBilling_Handler,
+		},
+		{
+			MethodName: "ListTransactionItem",
+			Handler:    _TransactionService_ListTransactionItem_Handler,
+		},
+		{
+			MethodName: "DetailBilling",
+			Handler:    _TransactionService_DetailBilling_Handler,
+		},
+		{
+			MethodName: "ProcessPayment",
+			Handler:    _TransactionService_ProcessPayment_Handler,
+		},
+		{
+			MethodName: "ApplyVoucher",
+			Handler:    _TransactionService_ApplyVoucher_Handler,
+		},
+		{
+			MethodName: "CancelBilling",
+			Handler:    _TransactionService_CancelBilling_Handler,
+		},
+		{
+			MethodName: "Refund",
+			Handler:    _TransactionService_Refund_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "transaction.proto",
+}
@@ -0,0 +1,148 @@
+package schemas
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const TRANSPORT_AMQP = "amqp"
+const TRANSPORT_GRPC = "grpc"
+
+// default address of every service when running the grpc transport
+const USER_GRPC_ADDR = "localhost:7001"
+const PRODUCT_GRPC_ADDR = "localhost:7002"
+const TRANSACTION_GRPC_ADDR = "localhost:7003"
+const ORDER_GRPC_ADDR = "localhost:7004"
+
+// CORRELATION_ID_METADATA is the grpc metadata key carrying the correlation
+// id of the graphql request.
+const CORRELATION_ID_METADATA = "correlation-id"
+
+var grpcCodes = map[codes.Code]string{
+	codes.OK:                 CODE_OK,
+	codes.InvalidArgument:    CODE_INVALID_REQUEST,
+	codes.NotFound:           CODE_NOT_FOUND,
+	codes.AlreadyExists:      CODE_DUPLICATE,
+	codes.FailedPrecondition: CODE_EXPIRED,
+	codes.Unavailable:        CODE_DATABASE_ERROR,
+	codes.DeadlineExceeded:   CODE_TIMEOUT,
+	codes.Internal:           CODE_INTERNAL_ERROR,
+}
+
+var replyGrpcCodes = map[string]codes.Code{
+	CODE_OK:              codes.OK,
+	CODE_INVALID_REQUEST: codes.InvalidArgument,
+	CODE_NOT_FOUND:       codes.NotFound,
+	CODE_DUPLICATE:       codes.AlreadyExists,
+	CODE_EXPIRED:         codes.FailedPrecondition,
+	CODE_DATABASE_ERROR:  codes.Unavailable,
+	CODE_TIMEOUT:         codes.DeadlineExceeded,
+	CODE_INTERNAL_ERROR:  codes.Internal,
+}
+
+// GrpcCode returns the grpc status code matching a reply code.
+func GrpcCode(code string) codes.Code {
+	c, ok := replyGrpcCodes[code]
+	if !ok {
+		return codes.Internal
+	}
+	return c
+}
+
+type grpc_rpc struct {
+	user_rpc        user_grpc
+	product_rpc     product_grpc
+	transaction_rpc transaction_grpc
+	order_rpc       order_grpc
+	conns           []*grpc.ClientConn
+}
+
+// InitGrpc builds the grpc clients of every service, dialing the addresses
+// set in the <SERVICE>_SERVICE_GRPC_ADDR variables.
+func InitGrpc() Rpc {
+	u := dialGrpc("USER_SERVICE_GRPC_ADDR", USER_GRPC_ADDR)
+	p := dialGrpc("PRODUCT_SERVICE_GRPC_ADDR", PRODUCT_GRPC_ADDR)
+	t := dialGrpc("TRANSACTION_SERVICE_GRPC_ADDR", TRANSACTION_GRPC_ADDR)
+	o := dialGrpc("ORDER_SERVICE_GRPC_ADDR", ORDER_GRPC_ADDR)
+	return grpc_rpc{
+		user_rpc:        newUserGrpc(u),
+		product_rpc:     newProductGrpc(p),
+		transaction_rpc: newTransactionGrpc(t),
+		order_rpc:       newOrderGrpc(o),
+		conns:           []*grpc.ClientConn{u, p, t, o},
+	}
+}
+
+func (r grpc_rpc) Close() error {
+	var err error
+	for _, conn := range r.conns {
+		if cErr := conn.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+func (r grpc_rpc) User() UserRPC {
+	return r.user_rpc
+}
+
+func (r grpc_rpc) Product() ProductRPC {
+	return r.product_rpc
+}
+
+func (r grpc_rpc) Transaction() TransactionRPC {
+	return r.transaction_rpc
+}
+
+func (r grpc_rpc) Order() OrderRPC {
+	return r.order_rpc
+}
+
+// dialGrpc connects lazily to the address found in env, or fallback when it
+// is not set.
+func dialGrpc(env, fallback string) *grpc.ClientConn {
+	addr := os.Getenv(env)
+	if addr == "" {
+		addr = fallback
+	}
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Panicf("Failed to dial %s: %s", addr, err)
+	}
+	log.Printf("Using grpc service at %s", addr)
+	return conn
+}
+
+func withCorrelationId(ctx context.Context, correlationId string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, CORRELATION_ID_METADATA, correlationId)
+}
+
+// grpcError turns the status returned by a grpc call into the same errors
+// the amqp clients return.
+func grpcError(method, correlationId string, err error) error {
+	if err == nil {
+		return nil
+	}
+	s := status.Convert(err)
+	code, ok := grpcCodes[s.Code()]
+	if !ok {
+		code = CODE_INTERNAL_ERROR
+	}
+	if code == CODE_TIMEOUT {
+		return &TimeoutError{Queue: method, CorrelationId: correlationId}
+	}
+	return &RPCError{
+		Queue:   method,
+		Status:  replyStatus[code],
+		Code:    code,
+		Message: s.Message(),
+	}
+}
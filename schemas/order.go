@@ -1,7 +1,6 @@
 package schemas
 
 import (
-	"errors"
 	"log"
 	"time"
 
@@ -15,13 +14,77 @@ type Order struct {
 	r      Rpc
 	ctx    *fiber.Ctx
 
-	Uuid     string      `json:"uuid,omitempty"`
-	UserUuid string      `json:"user_uuid,omitempty"`
-	Amount   int         `json:"amount,omitempty"`
-	Created  time.Time   `json:"created,omitempty"`
-	Items    []OrderItem `json:"items"`
+	Uuid         string               `json:"uuid,omitempty"`
+	UserUuid     string               `json:"user_uuid,omitempty"`
+	Amount       int                  `json:"amount,omitempty"`
+	Status       string               `json:"status,omitempty"`
+	RefundStatus string               `json:"refund_status,omitempty"`
+	Created      time.Time            `json:"created,omitempty"`
+	Items        []OrderItem          `json:"items"`
+	History      []OrderStatusHistory `json:"history,omitempty"`
+	// the bill the order is for and the reference of the payment which
+	// settled it, a bill has a single order
+	TransactionUuid  string `json:"transaction_uuid,omitempty"`
+	PaymentReference string `json:"payment_reference,omitempty"`
 }
 
+// status of an order, the order-service only allows the transitions
+// CREATED -> PROCESSING -> SHIPPED -> DELIVERED -> RETURNED, and CREATED or
+// PROCESSING -> CANCELLED
+const ORDER_STATUS_CREATED = "CREATED"
+const ORDER_STATUS_PROCESSING = "PROCESSING"
+const ORDER_STATUS_SHIPPED = "SHIPPED"
+const ORDER_STATUS_DELIVERED = "DELIVERED"
+const ORDER_STATUS_CANCELLED = "CANCELLED"
+const ORDER_STATUS_RETURNED = "RETURNED"
+
+// refund status of an order, empty when the bill of the order is not
+// refunded
+const ORDER_PARTIALLY_REFUNDED = "PARTIALLY_REFUNDED"
+const ORDER_REFUNDED = "REFUNDED"
+
+// OrderStatusHistory records a change of the status of an order, FromStatus
+// is empty for the creation of the order.
+type OrderStatusHistory struct {
+	FromStatus string    `json:"from_status,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	Created    time.Time `json:"created,omitempty"`
+}
+
+// OrderTransition asks to move the order to Status. The order has to belong
+// to the user when UserUuid is set.
+type OrderTransition struct {
+	OrderUuid string `json:"order_uuid,omitempty"`
+	UserUuid  string `json:"user_uuid,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Note      string `json:"note,omitempty"`
+}
+
+var typeOrderStatusEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "OrderStatus",
+	Values: graphql.EnumValueConfigMap{
+		ORDER_STATUS_CREATED: &graphql.EnumValueConfig{
+			Value: ORDER_STATUS_CREATED,
+		},
+		ORDER_STATUS_PROCESSING: &graphql.EnumValueConfig{
+			Value: ORDER_STATUS_PROCESSING,
+		},
+		ORDER_STATUS_SHIPPED: &graphql.EnumValueConfig{
+			Value: ORDER_STATUS_SHIPPED,
+		},
+		ORDER_STATUS_DELIVERED: &graphql.EnumValueConfig{
+			Value: ORDER_STATUS_DELIVERED,
+		},
+		ORDER_STATUS_CANCELLED: &graphql.EnumValueConfig{
+			Value: ORDER_STATUS_CANCELLED,
+		},
+		ORDER_STATUS_RETURNED: &graphql.EnumValueConfig{
+			Value: ORDER_STATUS_RETURNED,
+		},
+	},
+})
+
 type OrderItem struct {
 	OrderUuid   string `json:"order_uuid,omitempty"`
 	ProductUuid string `json:"product_uuid,omitempty"`
@@ -36,7 +99,13 @@ func orderType(name string, field []string) *graphql.Object {
 		"uuid":      typeString,
 		"user_uuid": typeString,
 		"amount":    typeInt,
-		"created":   typeDatetime,
+		"status": &graphql.Field{
+			Type: typeOrderStatusEnum,
+		},
+		"refund_status":     typeString,
+		"transaction_uuid":  typeString,
+		"payment_reference": typeString,
+		"created":           typeDatetime,
 	}
 	allowedField := getAllowedField(allField, field)
 	return graphql.NewObject(
@@ -65,11 +134,50 @@ func orderItemType(name string, field []string) *graphql.Object {
 	)
 }
 
+var typeOrderStatusHistory = graphql.NewObject(graphql.ObjectConfig{
+	Name: "order_status_history",
+	Fields: graphql.Fields{
+		"from_status": &graphql.Field{
+			Type: typeOrderStatusEnum,
+		},
+		"status": &graphql.Field{
+			Type: typeOrderStatusEnum,
+		},
+		"note":    typeString,
+		"created": typeDatetime,
+	},
+})
+
 func (o Order) orderQuery() *graphql.Field {
 	oT := orderType("order", []string{})
 	oT.AddFieldConfig("items", &graphql.Field{
 		Type: graphql.NewList(orderItemType("items", []string{})),
 	})
+	oT.AddFieldConfig("history", &graphql.Field{
+		Type: graphql.NewList(typeOrderStatusHistory),
+	})
+	oT.AddFieldConfig("transaction", &graphql.Field{
+		Type:        transactionType("order_transaction", []string{}),
+		Description: "Tagihan yang dibayar untuk pesanan ini",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			order := p.Source.(Order)
+			if order.TransactionUuid == "" {
+				return nil, nil
+			}
+			correlationId := o.ctx.Locals("uuid").(string)
+			trx, err := o.r.Transaction().DetailBilling(p.Context, correlationId, Transaction{
+				Uuid:     order.TransactionUuid,
+				UserUuid: order.UserUuid,
+			})
+			if IsNotFound(err) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, rpcError(err)
+			}
+			return trx, nil
+		},
+	})
 	return &graphql.Field{
 		Type: oT,
 		Args: graphql.FieldConfigArgument{
@@ -84,9 +192,12 @@ func (o Order) orderQuery() *graphql.Field {
 				Uuid:     p.Args["uuid"].(string),
 				UserUuid: user.Uuid,
 			}
-			order = o.r.Order().DetailOrder(p.Context, correlationId, order)
-			if order.Uuid == "" {
-				return nil, errors.New("order tidak ditemukan")
+			order, err := o.r.Order().DetailOrder(p.Context, correlationId, order)
+			if IsNotFound(err) {
+				return nil, newGraphqlError(CODE_NOT_FOUND, "order tidak ditemukan")
+			}
+			if err != nil {
+				return nil, rpcError(err)
 			}
 			return order, nil
 		},
@@ -99,12 +210,109 @@ func (o Order) ordersQuery() *graphql.Field {
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 			correlationId := o.ctx.Locals("uuid").(string)
 			user := o.ctx.Locals("user").(User)
-			orders := o.r.Order().ListOrder(p.Context, correlationId, user.Uuid)
+			orders, err := o.r.Order().ListOrder(p.Context, correlationId, user.Uuid)
+			if err != nil {
+				return nil, rpcError(err)
+			}
 			return orders, nil
 		},
 	}
 }
 
+// updateOrderStatusMutation is meant for the operators, the request has to
+// carry the key in OPS_API_KEY in the X-Ops-Key header.
+func (o Order) updateOrderStatusMutation() *graphql.Field {
+	return &graphql.Field{
+		Type:        orderType("updateOrderStatus", []string{}),
+		Description: "Mengubah status pesanan oleh operator",
+		Args: graphql.FieldConfigArgument{
+			"uuid": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"status": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(typeOrderStatusEnum),
+			},
+			"note": &graphql.ArgumentConfig{
+				Type: graphql.String,
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			correlationId := o.ctx.Locals("uuid").(string)
+			if !isOperator(o.ctx) {
+				return nil, newGraphqlError(CODE_INVALID_REQUEST, "status pesanan hanya dapat diubah oleh operator")
+			}
+			transition := OrderTransition{
+				OrderUuid: p.Args["uuid"].(string),
+				Status:    p.Args["status"].(string),
+			}
+			transition.Note, _ = p.Args["note"].(string)
+			return o.transition(p, correlationId, transition)
+		},
+	}
+}
+
+func (o Order) confirmDeliveryMutation() *graphql.Field {
+	return &graphql.Field{
+		Type:        orderType("confirmDelivery", []string{}),
+		Description: "Konfirmasi pesanan yang dikirim sudah diterima",
+		Args: graphql.FieldConfigArgument{
+			"uuid": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			correlationId := o.ctx.Locals("uuid").(string)
+			user := o.ctx.Locals("user").(User)
+			return o.transition(p, correlationId, OrderTransition{
+				OrderUuid: p.Args["uuid"].(string),
+				UserUuid:  user.Uuid,
+				Status:    ORDER_STATUS_DELIVERED,
+				Note:      "diterima oleh user",
+			})
+		},
+	}
+}
+
+func (o Order) cancelOrderMutation() *graphql.Field {
+	return &graphql.Field{
+		Type:        orderType("cancelOrder", []string{}),
+		Description: "Membatalkan pesanan yang belum dikirim, sisa pembayaran direfund dan stok dikembalikan",
+		Args: graphql.FieldConfigArgument{
+			"uuid": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"reason": &graphql.ArgumentConfig{
+				Type: graphql.String,
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			correlationId := o.ctx.Locals("uuid").(string)
+			user := o.ctx.Locals("user").(User)
+			transition := OrderTransition{
+				OrderUuid: p.Args["uuid"].(string),
+				UserUuid:  user.Uuid,
+				Status:    ORDER_STATUS_CANCELLED,
+			}
+			transition.Note, _ = p.Args["reason"].(string)
+			return o.transition(p, correlationId, transition)
+		},
+	}
+}
+
+func (o Order) transition(p graphql.ResolveParams, correlationId string, transition OrderTransition) (interface{}, error) {
+	order, err := o.r.Order().UpdateOrderStatus(p.Context, correlationId, transition)
+	if IsNotFound(err) {
+		return nil, newGraphqlError(CODE_NOT_FOUND, "order tidak ditemukan")
+	}
+	if ErrorCode(err) == CODE_INVALID_REQUEST {
+		return nil, newGraphqlError(CODE_INVALID_REQUEST, "status pesanan tidak dapat diubah menjadi "+transition.Status)
+	}
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return order, nil
+}
+
 func newOrder(r Rpc) Order {
 	order := Order{r: r}
 	return order
@@ -119,8 +327,17 @@ func (o Order) Context(c *fiber.Ctx) Schema {
 			"orders": order.ordersQuery(),
 		},
 	})
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "OrderMutation",
+		Fields: graphql.Fields{
+			"updateOrderStatus": order.updateOrderStatusMutation(),
+			"confirmDelivery":   order.confirmDeliveryMutation(),
+			"cancelOrder":       order.cancelOrderMutation(),
+		},
+	})
 	s, err := graphql.NewSchema(graphql.SchemaConfig{
-		Query: query,
+		Query:    query,
+		Mutation: mutation,
 	})
 	if err != nil {
 		log.Fatalf("Gagal membuat schema, error: %v", err.Error())
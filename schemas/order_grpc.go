@@ -0,0 +1,55 @@
+package schemas
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"wahyuade.com/simple-e-commerce/pb"
+)
+
+type order_grpc struct {
+	client pb.OrderServiceClient
+}
+
+func newOrderGrpc(conn *grpc.ClientConn) order_grpc {
+	return order_grpc{
+		client: pb.NewOrderServiceClient(conn),
+	}
+}
+
+func (o order_grpc) ListOrder(ctx context.Context, correlationId string, userUuid string) ([]Order, error) {
+	resp, err := o.client.ListOrder(withCorrelationId(ctx, correlationId), &pb.UserRequest{UserUuid: userUuid})
+	if err != nil {
+		return nil, grpcError(LIST_ORDER_QUEUE, correlationId, err)
+	}
+	return OrderListFromPB(resp), nil
+}
+
+func (o order_grpc) DetailOrder(ctx context.Context, correlationId string, order Order) (Order, error) {
+	resp, err := o.client.DetailOrder(withCorrelationId(ctx, correlationId), OrderToPB(order))
+	if err != nil {
+		return Order{}, grpcError(DETAIL_ORDER_QUEUE, correlationId, err)
+	}
+	return OrderFromPB(resp), nil
+}
+
+func (o order_grpc) ProcessOrder(ctx context.Context, correlationId string, order Order) (Order, error) {
+	resp, err := o.client.ProcessOrder(withCorrelationId(ctx, correlationId), OrderToPB(order))
+	if err != nil {
+		return Order{}, grpcError(PROCESS_ORDER_QUEUE, correlationId, err)
+	}
+	return OrderFromPB(resp), nil
+}
+
+func (o order_grpc) DeleteOrder(ctx context.Context, correlationId string, order Order) error {
+	_, err := o.client.DeleteOrder(withCorrelationId(ctx, correlationId), OrderToPB(order))
+	return grpcError(DELETE_ORDER_QUEUE, correlationId, err)
+}
+
+func (o order_grpc) UpdateOrderStatus(ctx context.Context, correlationId string, transition OrderTransition) (Order, error) {
+	resp, err := o.client.UpdateOrderStatus(withCorrelationId(ctx, correlationId), OrderTransitionToPB(transition))
+	if err != nil {
+		return Order{}, grpcError(UPDATE_ORDER_STATUS_QUEUE, correlationId, err)
+	}
+	return OrderFromPB(resp), nil
+}
@@ -4,57 +4,71 @@ import (
 	"context"
 	"encoding/json"
 
-	"github.com/rabbitmq/amqp091-go"
+	"wahyuade.com/simple-e-commerce/broker"
 )
 
 const TOPIC_ORDER = "order-service"
 
 const LIST_ORDER_QUEUE = "listOrderQueue"
-const LIST_ORDER_RSP_QUEUE = "listOrderQueueRsp"
 const DETAIL_ORDER_QUEUE = "detailOrderQueue"
-const DETAIL_ORDER_RSP_QUEUE = "detailOrderQueueRsp"
 const PROCESS_ORDER_QUEUE = "processOrderQueue"
-const PROCESS_ORDER_RSP_QUEUE = "processOrderQueueRsp"
+const DELETE_ORDER_QUEUE = "deleteOrderQueue"
+const UPDATE_ORDER_STATUS_QUEUE = "updateOrderStatusQueue"
 
 type OrderRPC interface {
-	ListOrder(ctx context.Context, correlationId string, userUuid string) []Order
-	DetailOrder(ctx context.Context, correlationId string, order Order) Order
-	ProcessOrder(ctx context.Context, correlationId string, order Order) Order
+	ListOrder(ctx context.Context, correlationId string, userUuid string) ([]Order, error)
+	DetailOrder(ctx context.Context, correlationId string, order Order) (Order, error)
+	ProcessOrder(ctx context.Context, correlationId string, order Order) (Order, error)
+	DeleteOrder(ctx context.Context, correlationId string, order Order) error
+	UpdateOrderStatus(ctx context.Context, correlationId string, transition OrderTransition) (Order, error)
 }
 
 type order_rpc struct {
-	conn *amqp091.Connection
+	t broker.Transport
 }
 
-func (o order_rpc) ListOrder(ctx context.Context, correlationId string, userUuid string) (orders []Order) {
-	ch := newChannel(o.conn, TOPIC_ORDER)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_ORDER, LIST_ORDER_QUEUE, LIST_ORDER_RSP_QUEUE, correlationId, []byte(userUuid))
-	if response != nil {
-		json.Unmarshal(response, &orders)
-		return orders
+func (o order_rpc) ListOrder(ctx context.Context, correlationId string, userUuid string) (orders []Order, err error) {
+	response, err := waitForResponse(ctx, o.t, TOPIC_ORDER, LIST_ORDER_QUEUE, correlationId, []byte(userUuid))
+	if err != nil {
+		return orders, err
 	}
-	return orders
+	err = decodeReply(LIST_ORDER_QUEUE, response, &orders)
+	return orders, err
 }
-func (o order_rpc) DetailOrder(ctx context.Context, correlationId string, or Order) (order Order) {
+func (o order_rpc) DetailOrder(ctx context.Context, correlationId string, or Order) (order Order, err error) {
 	requestPayload, _ := json.Marshal(or)
-	ch := newChannel(o.conn, TOPIC_ORDER)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_ORDER, DETAIL_ORDER_QUEUE, DETAIL_ORDER_RSP_QUEUE, correlationId, requestPayload)
-	if response != nil {
-		json.Unmarshal(response, &order)
-		return order
+	response, err := waitForResponse(ctx, o.t, TOPIC_ORDER, DETAIL_ORDER_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return order, err
 	}
-	return order
+	err = decodeReply(DETAIL_ORDER_QUEUE, response, &order)
+	return order, err
 }
-func (o order_rpc) ProcessOrder(ctx context.Context, correlationId string, or Order) (order Order) {
+func (o order_rpc) ProcessOrder(ctx context.Context, correlationId string, or Order) (order Order, err error) {
 	requestPayload, _ := json.Marshal(or)
-	ch := newChannel(o.conn, TOPIC_ORDER)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_ORDER, PROCESS_ORDER_QUEUE, PROCESS_ORDER_RSP_QUEUE, correlationId, requestPayload)
-	if response != nil {
-		json.Unmarshal(response, &order)
-		return order
+	response, err := waitForResponse(ctx, o.t, TOPIC_ORDER, PROCESS_ORDER_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return order, err
 	}
-	return order
+	err = decodeReply(PROCESS_ORDER_QUEUE, response, &order)
+	return order, err
+}
+
+func (o order_rpc) DeleteOrder(ctx context.Context, correlationId string, order Order) error {
+	requestPayload, _ := json.Marshal(order)
+	response, err := waitForResponse(ctx, o.t, TOPIC_ORDER, DELETE_ORDER_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return err
+	}
+	return decodeReply(DELETE_ORDER_QUEUE, response, nil)
+}
+
+func (o order_rpc) UpdateOrderStatus(ctx context.Context, correlationId string, transition OrderTransition) (order Order, err error) {
+	requestPayload, _ := json.Marshal(transition)
+	response, err := waitForResponse(ctx, o.t, TOPIC_ORDER, UPDATE_ORDER_STATUS_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return order, err
+	}
+	err = decodeReply(UPDATE_ORDER_STATUS_QUEUE, response, &order)
+	return order, err
 }
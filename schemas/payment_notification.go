@@ -0,0 +1,56 @@
+package schemas
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// PaymentNotification is sent by the payment provider to the gateway when a
+// virtual account is paid. Reference identifies the payment at the provider,
+// the same notification may be sent more than once.
+type PaymentNotification struct {
+	VirtualAccount string    `json:"virtual_account"`
+	Reference      string    `json:"reference"`
+	Amount         int       `json:"amount"`
+	PaidAt         time.Time `json:"paid_at"`
+}
+
+// the notification is signed with the hex HMAC-SHA256 of the timestamp, a dot
+// and the body, keyed by the secret shared with the provider
+const HEADER_NOTIFICATION_TIMESTAMP = "X-Callback-Timestamp"
+const HEADER_NOTIFICATION_SIGNATURE = "X-Callback-Signature"
+
+// NOTIFICATION_TOLERANCE is how far the timestamp of a notification may be
+// from the clock of the gateway, older notifications can not be replayed.
+const NOTIFICATION_TOLERANCE = 5 * time.Minute
+
+// PAYMENT_ALREADY_RECEIVED is the message of the duplicate error replied when
+// the payment with the same reference was already recorded for the bill.
+const PAYMENT_ALREADY_RECEIVED = "payment already received"
+
+// SignPaymentNotification returns the signature of body sent at timestamp.
+func SignPaymentNotification(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPaymentNotification tells whether signature is the one of body sent
+// at timestamp, a unix time in seconds, and timestamp is recent enough.
+func VerifyPaymentNotification(secret, timestamp, signature string, body []byte, now time.Time) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	sent := time.Unix(seconds, 0)
+	if sent.Before(now.Add(-NOTIFICATION_TOLERANCE)) || sent.After(now.Add(NOTIFICATION_TOLERANCE)) {
+		return false
+	}
+	expected := SignPaymentNotification(secret, sent, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
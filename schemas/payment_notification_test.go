@@ -0,0 +1,111 @@
+package schemas
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPaymentNotification(t *testing.T) {
+	secret := "secret"
+	body := []byte(`{"virtual_account":"3935801234567894","reference":"BCA-0001","amount":115000}`)
+	now := time.Unix(1760781600, 0)
+	sign := func(sent time.Time) (string, string) {
+		return strconv.FormatInt(sent.Unix(), 10), SignPaymentNotification(secret, sent, body)
+	}
+
+	tests := []struct {
+		name   string
+		verify func() bool
+		valid  bool
+	}{
+		{
+			name: "signed now",
+			verify: func() bool {
+				timestamp, signature := sign(now)
+				return VerifyPaymentNotification(secret, timestamp, signature, body, now)
+			},
+			valid: true,
+		},
+		{
+			name: "at the tolerance in the past",
+			verify: func() bool {
+				timestamp, signature := sign(now.Add(-NOTIFICATION_TOLERANCE))
+				return VerifyPaymentNotification(secret, timestamp, signature, body, now)
+			},
+			valid: true,
+		},
+		{
+			name: "at the tolerance in the future",
+			verify: func() bool {
+				timestamp, signature := sign(now.Add(NOTIFICATION_TOLERANCE))
+				return VerifyPaymentNotification(secret, timestamp, signature, body, now)
+			},
+			valid: true,
+		},
+		{
+			name: "older than the tolerance",
+			verify: func() bool {
+				timestamp, signature := sign(now.Add(-NOTIFICATION_TOLERANCE - time.Second))
+				return VerifyPaymentNotification(secret, timestamp, signature, body, now)
+			},
+		},
+		{
+			name: "newer than the tolerance",
+			verify: func() bool {
+				timestamp, signature := sign(now.Add(NOTIFICATION_TOLERANCE + time.Second))
+				return VerifyPaymentNotification(secret, timestamp, signature, body, now)
+			},
+		},
+		{
+			name: "signed with another secret",
+			verify: func() bool {
+				timestamp, _ := sign(now)
+				return VerifyPaymentNotification(secret, timestamp, SignPaymentNotification("other", now, body), body, now)
+			},
+		},
+		{
+			name: "body changed",
+			verify: func() bool {
+				timestamp, signature := sign(now)
+				return VerifyPaymentNotification(secret, timestamp, signature, []byte(`{"amount":1}`), now)
+			},
+		},
+		{
+			name: "timestamp changed",
+			verify: func() bool {
+				_, signature := sign(now)
+				timestamp, _ := sign(now.Add(time.Second))
+				return VerifyPaymentNotification(secret, timestamp, signature, body, now)
+			},
+		},
+		{
+			name: "empty signature",
+			verify: func() bool {
+				timestamp, _ := sign(now)
+				return VerifyPaymentNotification(secret, timestamp, "", body, now)
+			},
+		},
+		{
+			name: "non numeric timestamp",
+			verify: func() bool {
+				_, signature := sign(now)
+				return VerifyPaymentNotification(secret, now.Format(time.RFC3339), signature, body, now)
+			},
+		},
+		{
+			name: "empty timestamp",
+			verify: func() bool {
+				_, signature := sign(now)
+				return VerifyPaymentNotification(secret, "", signature, body, now)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.valid, tt.verify())
+		})
+	}
+}
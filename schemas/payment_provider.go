@@ -0,0 +1,157 @@
+package schemas
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// PaymentProvider is a bank accepting the payment of the bills through
+// virtual accounts.
+//
+// The virtual accounts of a provider are VALength digits long: the
+// CompanyPrefix, random digits and a Luhn check digit.
+type PaymentProvider struct {
+	Code          string
+	Name          string
+	CompanyPrefix string
+	VALength      int
+	// Fee is charged to the user on top of the bill, it is not taxed.
+	Fee int
+	// Expiry is how long a bill, and the stock reserved for it, waits for
+	// its payment.
+	Expiry time.Duration
+}
+
+var paymentProviders = map[string]PaymentProvider{}
+
+func init() {
+	RegisterPaymentProvider(PaymentProvider{
+		Code:          "BCA",
+		Name:          "Bank Central Asia",
+		CompanyPrefix: "39358",
+		VALength:      16,
+		Fee:           4000,
+		Expiry:        24 * time.Hour,
+	})
+	RegisterPaymentProvider(PaymentProvider{
+		Code:          "BNI",
+		Name:          "Bank Negara Indonesia",
+		CompanyPrefix: "9881234",
+		VALength:      16,
+		Fee:           4000,
+		Expiry:        24 * time.Hour,
+	})
+	RegisterPaymentProvider(PaymentProvider{
+		Code:          "BRI",
+		Name:          "Bank Rakyat Indonesia",
+		CompanyPrefix: "12345",
+		VALength:      15,
+		Fee:           3000,
+		Expiry:        12 * time.Hour,
+	})
+}
+
+// RegisterPaymentProvider adds p to the payment methods, replacing the
+// provider with the same code. It is meant to be called from an init
+// function, the PaymentMethod enum of the gateway is built once.
+func RegisterPaymentProvider(p PaymentProvider) {
+	if p.Code == "" || p.Expiry <= 0 || p.VALength <= len(p.CompanyPrefix)+1 {
+		panic(fmt.Sprintf("invalid payment provider %q", p.Code))
+	}
+	paymentProviders[p.Code] = p
+}
+
+// PaymentProviderOf returns the provider of the payment method.
+func PaymentProviderOf(code string) (PaymentProvider, bool) {
+	p, ok := paymentProviders[code]
+	return p, ok
+}
+
+// PaymentProviders returns the registered providers sorted by code.
+func PaymentProviders() []PaymentProvider {
+	var providers []PaymentProvider
+	for _, p := range paymentProviders {
+		providers = append(providers, p)
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Code < providers[j].Code
+	})
+	return providers
+}
+
+// GenerateVirtualAccount returns a new virtual account of the provider. Two
+// bills may still get the same one, the caller has to retry on collision.
+func (p PaymentProvider) GenerateVirtualAccount() (string, error) {
+	var va strings.Builder
+	va.WriteString(p.CompanyPrefix)
+	for va.Len() < p.VALength-1 {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		va.WriteByte(byte('0' + n.Int64()))
+	}
+	va.WriteByte(luhnDigit(va.String()))
+	return va.String(), nil
+}
+
+// ValidVirtualAccount tells whether va has the format of the virtual
+// accounts of the provider.
+func (p PaymentProvider) ValidVirtualAccount(va string) bool {
+	if len(va) != p.VALength || !strings.HasPrefix(va, p.CompanyPrefix) {
+		return false
+	}
+	for _, c := range va {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return luhnDigit(va[:len(va)-1]) == va[len(va)-1]
+}
+
+// luhnDigit returns the check digit of the Luhn algorithm for digits.
+func luhnDigit(digits string) byte {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+var paymentMethodOnce sync.Once
+var paymentMethodEnum *graphql.Enum
+
+// typePaymentMethod returns the PaymentMethod enum of the registered
+// providers.
+func typePaymentMethod() *graphql.Enum {
+	paymentMethodOnce.Do(func() {
+		values := graphql.EnumValueConfigMap{}
+		for _, p := range PaymentProviders() {
+			values[p.Code] = &graphql.EnumValueConfig{
+				Value:       p.Code,
+				Description: p.Name,
+			}
+		}
+		paymentMethodEnum = graphql.NewEnum(graphql.EnumConfig{
+			Name:   "PaymentMethod",
+			Values: values,
+		})
+	})
+	return paymentMethodEnum
+}
@@ -0,0 +1,60 @@
+package schemas
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLuhnDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		check  byte
+	}{
+		{"", '0'},
+		{"0", '0'},
+		{"7992739871", '3'},
+		{"411111111111111", '1'},
+		{"555555555555444", '4'},
+		{"37828224631000", '5'},
+		{"393580123456789", '4'},
+	}
+	for _, tt := range tests {
+		require.Equal(t, string(tt.check), string(luhnDigit(tt.digits)), tt.digits)
+	}
+}
+
+func TestValidVirtualAccount(t *testing.T) {
+	bca, ok := PaymentProviderOf("BCA")
+	require.True(t, ok)
+	tests := []struct {
+		va    string
+		valid bool
+	}{
+		{"3935801234567894", true},
+		{"3935801234567897", false},
+		{"393580123456789", false},
+		{"39358012345678940", false},
+		{"9881230123456785", false},
+		{"39358012345678a4", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.valid, bca.ValidVirtualAccount(tt.va), tt.va)
+	}
+}
+
+func TestGenerateVirtualAccount(t *testing.T) {
+	providers := PaymentProviders()
+	require.NotEmpty(t, providers)
+	for _, p := range providers {
+		for i := 0; i < 100; i++ {
+			va, err := p.GenerateVirtualAccount()
+			require.NoError(t, err)
+			require.Len(t, va, p.VALength, p.Code)
+			require.True(t, strings.HasPrefix(va, p.CompanyPrefix), "%s: %s", p.Code, va)
+			require.True(t, p.ValidVirtualAccount(va), "%s: %s", p.Code, va)
+		}
+	}
+}
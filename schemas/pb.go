@@ -0,0 +1,441 @@
+package schemas
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"wahyuade.com/simple-e-commerce/pb"
+)
+
+// converters between the schemas and the protobuf messages used by the grpc
+// transport
+
+func timeToPB(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func timeFromPB(t *timestamppb.Timestamp) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.AsTime()
+}
+
+func UserToPB(u User) *pb.User {
+	return &pb.User{
+		Uuid:     u.Uuid,
+		Name:     u.Name,
+		Email:    u.Email,
+		Password: u.Password,
+		Session:  u.Session,
+	}
+}
+
+func UserFromPB(u *pb.User) User {
+	return User{
+		Uuid:     u.GetUuid(),
+		Name:     u.GetName(),
+		Email:    u.GetEmail(),
+		Password: u.GetPassword(),
+		Session:  u.GetSession(),
+	}
+}
+
+func ProductToPB(p Product) *pb.Product {
+	return &pb.Product{
+		Uuid:        p.Uuid,
+		Name:        p.Name,
+		Description: p.Description,
+		Stock:       int64(p.Stock),
+		Price:       int64(p.Price),
+	}
+}
+
+func ProductFromPB(p *pb.Product) Product {
+	return Product{
+		Uuid:        p.GetUuid(),
+		Name:        p.GetName(),
+		Description: p.GetDescription(),
+		Stock:       int(p.GetStock()),
+		Price:       int(p.GetPrice()),
+	}
+}
+
+func StockReservationToPB(r StockReservation) *pb.StockReservation {
+	reservation := &pb.StockReservation{
+		TransactionUuid: r.TransactionUuid,
+		Expired:         timeToPB(r.Expired),
+	}
+	for _, i := range r.Items {
+		reservation.Items = append(reservation.Items, &pb.StockReservationItem{
+			ProductUuid: i.ProductUuid,
+			Qty:         int64(i.Qty),
+		})
+	}
+	return reservation
+}
+
+func StockReservationFromPB(r *pb.StockReservation) StockReservation {
+	reservation := StockReservation{
+		TransactionUuid: r.GetTransactionUuid(),
+		Expired:         timeFromPB(r.GetExpired()),
+	}
+	for _, i := range r.GetItems() {
+		reservation.Items = append(reservation.Items, StockReservationItem{
+			ProductUuid: i.GetProductUuid(),
+			Qty:         int(i.GetQty()),
+		})
+	}
+	return reservation
+}
+
+func StockReturnToPB(r StockReturn) *pb.StockReturn {
+	stockReturn := &pb.StockReturn{OrderUuid: r.OrderUuid}
+	for _, i := range r.Items {
+		stockReturn.Items = append(stockReturn.Items, &pb.StockReservationItem{
+			ProductUuid: i.ProductUuid,
+			Qty:         int64(i.Qty),
+		})
+	}
+	return stockReturn
+}
+
+func StockReturnFromPB(r *pb.StockReturn) StockReturn {
+	stockReturn := StockReturn{OrderUuid: r.GetOrderUuid()}
+	for _, i := range r.GetItems() {
+		stockReturn.Items = append(stockReturn.Items, StockReservationItem{
+			ProductUuid: i.GetProductUuid(),
+			Qty:         int(i.GetQty()),
+		})
+	}
+	return stockReturn
+}
+
+func ProductListToPB(products []Product) *pb.ProductList {
+	list := &pb.ProductList{}
+	for _, p := range products {
+		list.Products = append(list.Products, ProductToPB(p))
+	}
+	return list
+}
+
+func ProductListFromPB(list *pb.ProductList) []Product {
+	var products []Product
+	for _, p := range list.GetProducts() {
+		products = append(products, ProductFromPB(p))
+	}
+	return products
+}
+
+func TransactionCartToPB(c TransactionCart) *pb.TransactionCart {
+	return &pb.TransactionCart{
+		Uuid:            c.Uuid,
+		UserUuid:        c.UserUuid,
+		ProductUuid:     c.ProductUuid,
+		TransactionUuid: c.TransactionUuid,
+		Qty:             int64(c.Qty),
+	}
+}
+
+func TransactionCartFromPB(c *pb.TransactionCart) TransactionCart {
+	return TransactionCart{
+		Uuid:            c.GetUuid(),
+		UserUuid:        c.GetUserUuid(),
+		ProductUuid:     c.GetProductUuid(),
+		TransactionUuid: c.GetTransactionUuid(),
+		Qty:             int(c.GetQty()),
+	}
+}
+
+func TransactionCartListToPB(carts []TransactionCart) *pb.TransactionCartList {
+	list := &pb.TransactionCartList{}
+	for _, c := range carts {
+		list.Carts = append(list.Carts, TransactionCartToPB(c))
+	}
+	return list
+}
+
+func TransactionCartListFromPB(list *pb.TransactionCartList) []TransactionCart {
+	var carts []TransactionCart
+	for _, c := range list.GetCarts() {
+		carts = append(carts, TransactionCartFromPB(c))
+	}
+	return carts
+}
+
+func TransactionToPB(t Transaction) *pb.Transaction {
+	return &pb.Transaction{
+		Uuid:           t.Uuid,
+		UserUuid:       t.UserUuid,
+		Status:         t.Status,
+		Amount:         int64(t.Amount),
+		PaymentMethod:  t.PaymentMethod,
+		Created:        timeToPB(t.Created),
+		Expired:        timeToPB(t.Expired),
+		VirtualAccount: t.VirtualAccount,
+		VoucherCode:    t.VoucherCode,
+		OrderUuid:      t.OrderUuid,
+		Paid:           int64(t.Paid),
+		Credit:         int64(t.Credit),
+		Breakdown:      PriceBreakdownToPB(t.Breakdown),
+	}
+}
+
+func TransactionFromPB(t *pb.Transaction) Transaction {
+	return Transaction{
+		Uuid:           t.GetUuid(),
+		UserUuid:       t.GetUserUuid(),
+		Status:         t.GetStatus(),
+		Amount:         int(t.GetAmount()),
+		PaymentMethod:  t.GetPaymentMethod(),
+		Created:        timeFromPB(t.GetCreated()),
+		Expired:        timeFromPB(t.GetExpired()),
+		VirtualAccount: t.GetVirtualAccount(),
+		VoucherCode:    t.GetVoucherCode(),
+		OrderUuid:      t.GetOrderUuid(),
+		Paid:           int(t.GetPaid()),
+		Credit:         int(t.GetCredit()),
+		Breakdown:      PriceBreakdownFromPB(t.GetBreakdown()),
+	}
+}
+
+func TransactionRefundToPB(r TransactionRefund) *pb.TransactionRefund {
+	refund := &pb.TransactionRefund{
+		Uuid:              r.Uuid,
+		TransactionUuid:   r.TransactionUuid,
+		OrderUuid:         r.OrderUuid,
+		Amount:            int64(r.Amount),
+		Reason:            r.Reason,
+		TransactionStatus: r.TransactionStatus,
+		Created:           timeToPB(r.Created),
+	}
+	for _, i := range r.Items {
+		refund.Items = append(refund.Items, &pb.TransactionRefundItem{
+			ProductUuid: i.ProductUuid,
+			Qty:         int64(i.Qty),
+		})
+	}
+	return refund
+}
+
+func TransactionRefundFromPB(r *pb.TransactionRefund) TransactionRefund {
+	refund := TransactionRefund{
+		Uuid:              r.GetUuid(),
+		TransactionUuid:   r.GetTransactionUuid(),
+		OrderUuid:         r.GetOrderUuid(),
+		Amount:            int(r.GetAmount()),
+		Reason:            r.GetReason(),
+		TransactionStatus: r.GetTransactionStatus(),
+		Created:           timeFromPB(r.GetCreated()),
+	}
+	for _, i := range r.GetItems() {
+		refund.Items = append(refund.Items, TransactionRefundItem{
+			ProductUuid: i.GetProductUuid(),
+			Qty:         int(i.GetQty()),
+		})
+	}
+	return refund
+}
+
+func PriceBreakdownToPB(b *PriceBreakdown) *pb.PriceBreakdown {
+	if b == nil {
+		return nil
+	}
+	breakdown := &pb.PriceBreakdown{
+		Subtotal: int64(b.Subtotal),
+		Discount: int64(b.Discount),
+		Tax:      int64(b.Tax),
+		Fee:      int64(b.Fee),
+		Total:    int64(b.Total),
+	}
+	for _, l := range b.Lines {
+		breakdown.Lines = append(breakdown.Lines, &pb.PriceLine{
+			ProductUuid: l.ProductUuid,
+			Name:        l.Name,
+			Price:       int64(l.Price),
+			Qty:         int64(l.Qty),
+			Total:       int64(l.Total),
+		})
+	}
+	for _, d := range b.Discounts {
+		breakdown.Discounts = append(breakdown.Discounts, &pb.PriceDiscount{
+			Code:        d.Code,
+			Description: d.Description,
+			Amount:      int64(d.Amount),
+		})
+	}
+	return breakdown
+}
+
+func PriceBreakdownFromPB(b *pb.PriceBreakdown) *PriceBreakdown {
+	if b == nil {
+		return nil
+	}
+	breakdown := &PriceBreakdown{
+		Subtotal: int(b.GetSubtotal()),
+		Discount: int(b.GetDiscount()),
+		Tax:      int(b.GetTax()),
+		Fee:      int(b.GetFee()),
+		Total:    int(b.GetTotal()),
+	}
+	for _, l := range b.GetLines() {
+		breakdown.Lines = append(breakdown.Lines, PriceLine{
+			ProductUuid: l.GetProductUuid(),
+			Name:        l.GetName(),
+			Price:       int(l.GetPrice()),
+			Qty:         int(l.GetQty()),
+			Total:       int(l.GetTotal()),
+		})
+	}
+	for _, d := range b.GetDiscounts() {
+		breakdown.Discounts = append(breakdown.Discounts, PriceDiscount{
+			Code:        d.GetCode(),
+			Description: d.GetDescription(),
+			Amount:      int(d.GetAmount()),
+		})
+	}
+	return breakdown
+}
+
+func TransactionPaymentToPB(p TransactionPayment) *pb.TransactionPayment {
+	return &pb.TransactionPayment{
+		Uuid:            p.Uuid,
+		TransactionUuid: p.TransactionUuid,
+		Reference:       p.Reference,
+		Amount:          int64(p.Amount),
+		PaymentDatetime: timeToPB(p.PaymentDatetime),
+	}
+}
+
+func TransactionPaymentFromPB(p *pb.TransactionPayment) TransactionPayment {
+	return TransactionPayment{
+		Uuid:            p.GetUuid(),
+		TransactionUuid: p.GetTransactionUuid(),
+		Reference:       p.GetReference(),
+		Amount:          int(p.GetAmount()),
+		PaymentDatetime: timeFromPB(p.GetPaymentDatetime()),
+	}
+}
+
+func PaymentFlagToPB(pf PaymentFlag) *pb.PaymentFlag {
+	return &pb.PaymentFlag{
+		Transaction: TransactionToPB(pf.Transaction),
+		Payment:     TransactionPaymentToPB(pf.Payment),
+	}
+}
+
+func PaymentFlagFromPB(pf *pb.PaymentFlag) PaymentFlag {
+	return PaymentFlag{
+		Transaction: TransactionFromPB(pf.GetTransaction()),
+		Payment:     TransactionPaymentFromPB(pf.GetPayment()),
+	}
+}
+
+func OrderItemToPB(i OrderItem) *pb.OrderItem {
+	return &pb.OrderItem{
+		OrderUuid:   i.OrderUuid,
+		ProductUuid: i.ProductUuid,
+		Name:        i.Name,
+		Description: i.Description,
+		Price:       int64(i.Price),
+		Qty:         int64(i.Qty),
+	}
+}
+
+func OrderItemFromPB(i *pb.OrderItem) OrderItem {
+	return OrderItem{
+		OrderUuid:   i.GetOrderUuid(),
+		ProductUuid: i.GetProductUuid(),
+		Name:        i.GetName(),
+		Description: i.GetDescription(),
+		Price:       int(i.GetPrice()),
+		Qty:         int(i.GetQty()),
+	}
+}
+
+func OrderToPB(o Order) *pb.Order {
+	order := &pb.Order{
+		Uuid:             o.Uuid,
+		UserUuid:         o.UserUuid,
+		Amount:           int64(o.Amount),
+		Status:           o.Status,
+		RefundStatus:     o.RefundStatus,
+		TransactionUuid:  o.TransactionUuid,
+		PaymentReference: o.PaymentReference,
+		Created:          timeToPB(o.Created),
+	}
+	for _, i := range o.Items {
+		order.Items = append(order.Items, OrderItemToPB(i))
+	}
+	for _, h := range o.History {
+		order.History = append(order.History, &pb.OrderStatusHistory{
+			FromStatus: h.FromStatus,
+			Status:     h.Status,
+			Note:       h.Note,
+			Created:    timeToPB(h.Created),
+		})
+	}
+	return order
+}
+
+func OrderFromPB(o *pb.Order) Order {
+	order := Order{
+		Uuid:             o.GetUuid(),
+		UserUuid:         o.GetUserUuid(),
+		Amount:           int(o.GetAmount()),
+		Status:           o.GetStatus(),
+		RefundStatus:     o.GetRefundStatus(),
+		TransactionUuid:  o.GetTransactionUuid(),
+		PaymentReference: o.GetPaymentReference(),
+		Created:          timeFromPB(o.GetCreated()),
+	}
+	for _, i := range o.GetItems() {
+		order.Items = append(order.Items, OrderItemFromPB(i))
+	}
+	for _, h := range o.GetHistory() {
+		order.History = append(order.History, OrderStatusHistory{
+			FromStatus: h.GetFromStatus(),
+			Status:     h.GetStatus(),
+			Note:       h.GetNote(),
+			Created:    timeFromPB(h.GetCreated()),
+		})
+	}
+	return order
+}
+
+func OrderTransitionToPB(t OrderTransition) *pb.OrderTransition {
+	return &pb.OrderTransition{
+		OrderUuid: t.OrderUuid,
+		UserUuid:  t.UserUuid,
+		Status:    t.Status,
+		Note:      t.Note,
+	}
+}
+
+func OrderTransitionFromPB(t *pb.OrderTransition) OrderTransition {
+	return OrderTransition{
+		OrderUuid: t.GetOrderUuid(),
+		UserUuid:  t.GetUserUuid(),
+		Status:    t.GetStatus(),
+		Note:      t.GetNote(),
+	}
+}
+
+func OrderListToPB(orders []Order) *pb.OrderList {
+	list := &pb.OrderList{}
+	for _, o := range orders {
+		list.Orders = append(list.Orders, OrderToPB(o))
+	}
+	return list
+}
+
+func OrderListFromPB(list *pb.OrderList) []Order {
+	var orders []Order
+	for _, o := range list.GetOrders() {
+		orders = append(orders, OrderFromPB(o))
+	}
+	return orders
+}
@@ -1,8 +1,8 @@
 package schemas
 
 import (
-	"errors"
 	"log"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/graphql-go/graphql"
@@ -21,6 +21,26 @@ type Product struct {
 	Price       int    `json:"price,omitempty"`
 }
 
+// StockReservation holds the stock of the items of a bill until it is paid
+// or it expires.
+type StockReservation struct {
+	TransactionUuid string                 `json:"transaction_uuid,omitempty"`
+	Expired         time.Time              `json:"expired,omitempty"`
+	Items           []StockReservationItem `json:"items,omitempty"`
+}
+
+type StockReservationItem struct {
+	ProductUuid string `json:"product_uuid,omitempty"`
+	Qty         int    `json:"qty,omitempty"`
+}
+
+// StockReturn puts the stock of the items of a cancelled order back, the
+// stock of an order is only returned once.
+type StockReturn struct {
+	OrderUuid string                 `json:"order_uuid,omitempty"`
+	Items     []StockReservationItem `json:"items,omitempty"`
+}
+
 func productType(name string, field []string) *graphql.Object {
 	allField := map[string]*graphql.Field{
 		"uuid":        typeString,
@@ -43,7 +63,11 @@ func (pR Product) productsQuery() *graphql.Field {
 		Description: "Lihat semua produk yang ada",
 		Type:        graphql.NewList(productType("products", []string{"uuid", "name", "price"})),
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-			return pR.r.Product().ListProduct(p.Context, pR.ctx.Locals("uuid").(string)), nil
+			products, err := pR.r.Product().ListProduct(p.Context, pR.ctx.Locals("uuid").(string))
+			if err != nil {
+				return nil, rpcError(err)
+			}
+			return products, nil
 		},
 	}
 }
@@ -58,11 +82,14 @@ func (pR Product) productQuery() *graphql.Field {
 			},
 		},
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
-			status, product := pR.r.Product().DetailProduct(p.Context, pR.ctx.Locals("uuid").(string), p.Args["uuid"].(string))
-			if status {
-				return product, nil
+			product, err := pR.r.Product().DetailProduct(p.Context, pR.ctx.Locals("uuid").(string), p.Args["uuid"].(string))
+			if IsNotFound(err) {
+				return nil, newGraphqlError(CODE_NOT_FOUND, "produk tidak ditemukan")
+			}
+			if err != nil {
+				return nil, rpcError(err)
 			}
-			return nil, errors.New("produk tidak ditemukan")
+			return product, nil
 		},
 	}
 }
@@ -92,15 +119,15 @@ func (pR Product) createProductMutation() *graphql.Field {
 				Stock:       p.Args["stock"].(int),
 				Price:       p.Args["price"].(int),
 			}
-			status, product := pR.r.Product().CreateProduct(
+			product, err := pR.r.Product().CreateProduct(
 				p.Context,
 				pR.ctx.Locals("uuid").(string),
 				product,
 			)
-			if status {
-				return product, nil
+			if err != nil {
+				return nil, rpcError(err)
 			}
-			return nil, errors.New("internal server error")
+			return product, nil
 		},
 	}
 }
@@ -0,0 +1,62 @@
+package schemas
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"wahyuade.com/simple-e-commerce/pb"
+)
+
+type product_grpc struct {
+	client pb.ProductServiceClient
+}
+
+func newProductGrpc(conn *grpc.ClientConn) product_grpc {
+	return product_grpc{
+		client: pb.NewProductServiceClient(conn),
+	}
+}
+
+func (p product_grpc) CreateProduct(ctx context.Context, correlationId string, product Product) (Product, error) {
+	resp, err := p.client.CreateProduct(withCorrelationId(ctx, correlationId), ProductToPB(product))
+	if err != nil {
+		return Product{}, grpcError(CREATE_PRODUCT_QUEUE, correlationId, err)
+	}
+	return ProductFromPB(resp), nil
+}
+
+func (p product_grpc) ListProduct(ctx context.Context, correlationId string) ([]Product, error) {
+	resp, err := p.client.ListProduct(withCorrelationId(ctx, correlationId), &pb.Empty{})
+	if err != nil {
+		return nil, grpcError(LIST_PRODUCT_QUEUE, correlationId, err)
+	}
+	return ProductListFromPB(resp), nil
+}
+
+func (p product_grpc) DetailProduct(ctx context.Context, correlationId, productUuid string) (Product, error) {
+	resp, err := p.client.DetailProduct(withCorrelationId(ctx, correlationId), &pb.ProductRequest{ProductUuid: productUuid})
+	if err != nil {
+		return Product{}, grpcError(DETAIL_PRODUCT_QUEUE, correlationId, err)
+	}
+	return ProductFromPB(resp), nil
+}
+
+func (p product_grpc) ReserveStock(ctx context.Context, correlationId string, reservation StockReservation) error {
+	_, err := p.client.ReserveStock(withCorrelationId(ctx, correlationId), StockReservationToPB(reservation))
+	return grpcError(RESERVE_STOCK_QUEUE, correlationId, err)
+}
+
+func (p product_grpc) CommitStock(ctx context.Context, correlationId, transactionUuid string) error {
+	_, err := p.client.CommitStock(withCorrelationId(ctx, correlationId), &pb.StockReservation{TransactionUuid: transactionUuid})
+	return grpcError(COMMIT_STOCK_QUEUE, correlationId, err)
+}
+
+func (p product_grpc) ReleaseStock(ctx context.Context, correlationId, transactionUuid string) error {
+	_, err := p.client.ReleaseStock(withCorrelationId(ctx, correlationId), &pb.StockReservation{TransactionUuid: transactionUuid})
+	return grpcError(RELEASE_STOCK_QUEUE, correlationId, err)
+}
+
+func (p product_grpc) RestoreOrderStock(ctx context.Context, correlationId string, stockReturn StockReturn) error {
+	_, err := p.client.RestoreOrderStock(withCorrelationId(ctx, correlationId), StockReturnToPB(stockReturn))
+	return grpcError(RESTORE_ORDER_STOCK_QUEUE, correlationId, err)
+}
@@ -4,70 +4,99 @@ import (
 	"context"
 	"encoding/json"
 
-	"github.com/rabbitmq/amqp091-go"
+	"wahyuade.com/simple-e-commerce/broker"
 )
 
 const TOPIC_PRODUCT = "product-service"
 
 const CREATE_PRODUCT_QUEUE = "createProduct"
-const CREATE_PRODUCT_RSP_QUEUE = "createProductRsp"
 const LIST_PRODUCT_QUEUE = "listProduct"
-const LIST_PRODUCT_RSP_QUEUE = "listProductRsp"
 const DETAIL_PRODUCT_QUEUE = "detailProduct"
-const DETAIL_PRODUCT_RSP_QUEUE = "detailProductRsp"
-const DECREMENT_PRODUCT_STOCK_QUEUE = "decrementProductStock"
-const DECREMENT_PRODUCT_STOCK_RSP_QUEUE = "decrementProductStockRsp"
+const RESERVE_STOCK_QUEUE = "reserveStock"
+const COMMIT_STOCK_QUEUE = "commitStock"
+const RELEASE_STOCK_QUEUE = "releaseStock"
+const RESTORE_ORDER_STOCK_QUEUE = "restoreOrderStock"
 
 type ProductRPC interface {
-	CreateProduct(ctx context.Context, correlationId string, product Product) (bool, Product)
-	ListProduct(ctx context.Context, correlationId string) []Product
-	DetailProduct(ctx context.Context, correlationId, productUuid string) (bool, Product)
-	DecrementStock(ctx context.Context, correlationId string, product Product) bool
+	CreateProduct(ctx context.Context, correlationId string, product Product) (Product, error)
+	ListProduct(ctx context.Context, correlationId string) ([]Product, error)
+	DetailProduct(ctx context.Context, correlationId, productUuid string) (Product, error)
+	// ReserveStock takes the stock of the items out until the reservation is
+	// committed or released, once per transaction.
+	ReserveStock(ctx context.Context, correlationId string, reservation StockReservation) error
+	// CommitStock keeps the stock reserved for the transaction for good.
+	CommitStock(ctx context.Context, correlationId, transactionUuid string) error
+	// ReleaseStock gives the stock reserved for the transaction back.
+	ReleaseStock(ctx context.Context, correlationId, transactionUuid string) error
+	// RestoreOrderStock gives the stock of the items of the cancelled order
+	// back, less what its refunds gave back already.
+	RestoreOrderStock(ctx context.Context, correlationId string, stockReturn StockReturn) error
 }
 
 type product_rpc struct {
-	conn *amqp091.Connection
+	t broker.Transport
 }
 
-func (p product_rpc) CreateProduct(ctx context.Context, correlationId string, product Product) (bool, Product) {
+func (p product_rpc) CreateProduct(ctx context.Context, correlationId string, product Product) (created Product, err error) {
 	requestPayload, _ := json.Marshal(product)
-	ch := newChannel(p.conn, TOPIC_PRODUCT)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_PRODUCT, CREATE_PRODUCT_QUEUE, CREATE_PRODUCT_RSP_QUEUE, correlationId, requestPayload)
-	responseProduct := Product{}
-	if response != nil {
-		json.Unmarshal(response, &responseProduct)
+	response, err := waitForResponse(ctx, p.t, TOPIC_PRODUCT, CREATE_PRODUCT_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return created, err
 	}
-	return responseProduct.Uuid != "", responseProduct
+	err = decodeReply(CREATE_PRODUCT_QUEUE, response, &created)
+	return created, err
 }
 
-func (p product_rpc) ListProduct(ctx context.Context, correlationId string) (products []Product) {
-	ch := newChannel(p.conn, TOPIC_PRODUCT)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_PRODUCT, LIST_PRODUCT_QUEUE, LIST_PRODUCT_RSP_QUEUE, correlationId, nil)
-	if response != nil {
-		json.Unmarshal(response, &products)
+func (p product_rpc) ListProduct(ctx context.Context, correlationId string) (products []Product, err error) {
+	response, err := waitForResponse(ctx, p.t, TOPIC_PRODUCT, LIST_PRODUCT_QUEUE, correlationId, nil)
+	if err != nil {
+		return products, err
 	}
-	return products
+	err = decodeReply(LIST_PRODUCT_QUEUE, response, &products)
+	return products, err
 }
 
-func (p product_rpc) DetailProduct(ctx context.Context, correlationId, productUuid string) (status bool, product Product) {
-	ch := newChannel(p.conn, TOPIC_PRODUCT)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_PRODUCT, DETAIL_PRODUCT_QUEUE, DETAIL_PRODUCT_RSP_QUEUE, correlationId, []byte(productUuid))
-	if response != nil {
-		json.Unmarshal(response, &product)
+func (p product_rpc) DetailProduct(ctx context.Context, correlationId, productUuid string) (product Product, err error) {
+	response, err := waitForResponse(ctx, p.t, TOPIC_PRODUCT, DETAIL_PRODUCT_QUEUE, correlationId, []byte(productUuid))
+	if err != nil {
+		return product, err
 	}
-	return product.Uuid != "", product
+	err = decodeReply(DETAIL_PRODUCT_QUEUE, response, &product)
+	return product, err
 }
 
-func (p product_rpc) DecrementStock(ctx context.Context, correlationId string, product Product) bool {
-	requestPayload, _ := json.Marshal(product)
-	ch := newChannel(p.conn, TOPIC_PRODUCT)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_PRODUCT, DECREMENT_PRODUCT_STOCK_QUEUE, DECREMENT_PRODUCT_STOCK_RSP_QUEUE, correlationId, requestPayload)
-	if response != nil {
-		json.Unmarshal(response, &product)
+func (p product_rpc) ReserveStock(ctx context.Context, correlationId string, reservation StockReservation) error {
+	requestPayload, _ := json.Marshal(reservation)
+	response, err := waitForResponse(ctx, p.t, TOPIC_PRODUCT, RESERVE_STOCK_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return err
+	}
+	return decodeReply(RESERVE_STOCK_QUEUE, response, nil)
+}
+
+func (p product_rpc) CommitStock(ctx context.Context, correlationId, transactionUuid string) error {
+	requestPayload, _ := json.Marshal(StockReservation{TransactionUuid: transactionUuid})
+	response, err := waitForResponse(ctx, p.t, TOPIC_PRODUCT, COMMIT_STOCK_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return err
+	}
+	return decodeReply(COMMIT_STOCK_QUEUE, response, nil)
+}
+
+func (p product_rpc) ReleaseStock(ctx context.Context, correlationId, transactionUuid string) error {
+	requestPayload, _ := json.Marshal(StockReservation{TransactionUuid: transactionUuid})
+	response, err := waitForResponse(ctx, p.t, TOPIC_PRODUCT, RELEASE_STOCK_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return err
+	}
+	return decodeReply(RELEASE_STOCK_QUEUE, response, nil)
+}
+
+func (p product_rpc) RestoreOrderStock(ctx context.Context, correlationId string, stockReturn StockReturn) error {
+	requestPayload, _ := json.Marshal(stockReturn)
+	response, err := waitForResponse(ctx, p.t, TOPIC_PRODUCT, RESTORE_ORDER_STOCK_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return err
 	}
-	return string(response) == "true"
+	return decodeReply(RESTORE_ORDER_STOCK_QUEUE, response, nil)
 }
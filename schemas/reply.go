@@ -0,0 +1,125 @@
+package schemas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// error codes carried by the Reply envelope
+const CODE_OK = "OK"
+const CODE_INVALID_REQUEST = "INVALID_REQUEST"
+const CODE_NOT_FOUND = "NOT_FOUND"
+const CODE_DUPLICATE = "DUPLICATE"
+const CODE_EXPIRED = "EXPIRED"
+const CODE_DATABASE_ERROR = "DATABASE_ERROR"
+const CODE_INTERNAL_ERROR = "INTERNAL_ERROR"
+const CODE_TIMEOUT = "TIMEOUT"
+
+var replyStatus = map[string]int{
+	CODE_OK:              200,
+	CODE_INVALID_REQUEST: 400,
+	CODE_NOT_FOUND:       404,
+	CODE_DUPLICATE:       409,
+	CODE_EXPIRED:         410,
+	CODE_INTERNAL_ERROR:  500,
+	CODE_DATABASE_ERROR:  503,
+	CODE_TIMEOUT:         504,
+}
+
+// Reply is the envelope sent back by every service handler.
+type Reply struct {
+	Status  int             `json:"status"`
+	Code    string          `json:"code"`
+	Message string          `json:"message,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+func NewReply(payload interface{}) Reply {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return NewErrorReply(CODE_INTERNAL_ERROR, err.Error())
+	}
+	return Reply{
+		Status:  replyStatus[CODE_OK],
+		Code:    CODE_OK,
+		Payload: body,
+	}
+}
+
+func NewErrorReply(code, message string) Reply {
+	status, ok := replyStatus[code]
+	if !ok {
+		status = replyStatus[CODE_INTERNAL_ERROR]
+	}
+	return Reply{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// RPCError is returned by the RPC clients when a service replies with an
+// error envelope.
+type RPCError struct {
+	Queue   string
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s replied %d %s: %s", e.Queue, e.Status, e.Code, e.Message)
+}
+
+// ErrorCode returns the reply code carried by err, CODE_TIMEOUT when the
+// deadline passed, or CODE_INTERNAL_ERROR for anything else.
+func ErrorCode(err error) string {
+	if err == nil {
+		return CODE_OK
+	}
+	var rErr *RPCError
+	if errors.As(err, &rErr) {
+		return rErr.Code
+	}
+	if IsTimeout(err) {
+		return CODE_TIMEOUT
+	}
+	return CODE_INTERNAL_ERROR
+}
+
+func IsNotFound(err error) bool {
+	return ErrorCode(err) == CODE_NOT_FOUND
+}
+
+func IsDuplicate(err error) bool {
+	return ErrorCode(err) == CODE_DUPLICATE
+}
+
+func IsExpired(err error) bool {
+	return ErrorCode(err) == CODE_EXPIRED
+}
+
+// decodeReply unwraps the envelope of response into out, turning error
+// envelopes into *RPCError.
+func decodeReply(queue string, response []byte, out interface{}) error {
+	var r Reply
+	if err := json.Unmarshal(response, &r); err != nil {
+		return fmt.Errorf("invalid reply from %s: %w", queue, err)
+	}
+	if r.Code != CODE_OK {
+		return &RPCError{
+			Queue:   queue,
+			Status:  r.Status,
+			Code:    r.Code,
+			Message: r.Message,
+		}
+	}
+	if out == nil || len(r.Payload) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(r.Payload, out); err != nil {
+		return fmt.Errorf("invalid payload from %s: %w", queue, err)
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package schemas
 
 import (
+	"crypto/subtle"
+	"log"
+	"os"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/graphql-go/graphql"
 )
@@ -30,6 +34,8 @@ type ISchema interface {
 	Product() Schema
 	Transaction() Schema
 	Order() Schema
+	// Close closes the connections of the RPC clients.
+	Close() error
 }
 
 type schemas struct {
@@ -50,8 +56,17 @@ func (s schemas) Transaction() Schema {
 	return newTransaction(s.rpc)
 }
 
+func (s schemas) Close() error {
+	return s.rpc.Close()
+}
+
 func Construct() ISchema {
-	rpc := InitRpc()
+	return ConstructWith(InitRpc())
+}
+
+// ConstructWith builds the schemas on top of the given clients, e.g. the ones
+// returned by NewRpc(broker.NewMemory()).
+func ConstructWith(rpc Rpc) ISchema {
 	return schemas{
 		rpc: rpc,
 	}
@@ -70,3 +85,54 @@ func getAllowedField(allField map[string]*graphql.Field, allowedField []string)
 	}
 	return &fields
 }
+
+var rpcErrorMessage = map[string]string{
+	CODE_INVALID_REQUEST: "permintaan tidak valid",
+	CODE_NOT_FOUND:       "data tidak ditemukan",
+	CODE_DUPLICATE:       "data sudah ada",
+	CODE_EXPIRED:         "data sudah kedaluwarsa",
+	CODE_DATABASE_ERROR:  "database sedang bermasalah, silahkan coba lagi",
+	CODE_TIMEOUT:         "layanan sedang tidak merespon, silahkan coba lagi",
+	CODE_INTERNAL_ERROR:  "internal server error",
+}
+
+// graphqlError exposes the reply code as the "code" extension of the
+// GraphQL error.
+type graphqlError struct {
+	code    string
+	message string
+}
+
+func (e graphqlError) Error() string {
+	return e.message
+}
+
+func (e graphqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code": e.code,
+	}
+}
+
+func newGraphqlError(code, message string) error {
+	return graphqlError{code: code, message: message}
+}
+
+// isOperator tells whether the request carries the key in OPS_API_KEY in the
+// X-Ops-Key header, the mutations meant for the operators require it.
+func isOperator(c *fiber.Ctx) bool {
+	opsKey := os.Getenv("OPS_API_KEY")
+	return opsKey != "" && subtle.ConstantTimeCompare([]byte(c.Get("X-Ops-Key")), []byte(opsKey)) == 1
+}
+
+// rpcError converts an error from the RPC clients into the error returned to
+// the GraphQL caller.
+func rpcError(err error) error {
+	log.Println(err)
+	code := ErrorCode(err)
+	message, ok := rpcErrorMessage[code]
+	if !ok {
+		code = CODE_INTERNAL_ERROR
+		message = rpcErrorMessage[code]
+	}
+	return newGraphqlError(code, message)
+}
@@ -1,7 +1,6 @@
 package schemas
 
 import (
-	"context"
 	"errors"
 	"log"
 	"time"
@@ -16,15 +15,48 @@ type Transaction struct {
 	r      Rpc
 	ctx    *fiber.Ctx
 
-	Uuid           string            `json:"uuid,omitempty"`
-	UserUuid       string            `json:"user_uuid,omitempty"`
-	Status         string            `json:"status,omitempty"`
-	Amount         int               `json:"amount,omitempty"`
-	PaymentMethod  string            `json:"payment_method,omitempty"`
-	Created        time.Time         `json:"created,omitempty"`
-	Expired        time.Time         `json:"expired,omitempty"`
-	VirtualAccount string            `json:"virtual_account,omitempty"`
-	Items          []TransactionCart `json:"items,omitempty"`
+	Uuid           string    `json:"uuid,omitempty"`
+	UserUuid       string    `json:"user_uuid,omitempty"`
+	Status         string    `json:"status,omitempty"`
+	Amount         int       `json:"amount,omitempty"`
+	PaymentMethod  string    `json:"payment_method,omitempty"`
+	Created        time.Time `json:"created,omitempty"`
+	Expired        time.Time `json:"expired,omitempty"`
+	VirtualAccount string    `json:"virtual_account,omitempty"`
+	VoucherCode    string    `json:"voucher_code,omitempty"`
+	OrderUuid      string    `json:"order_uuid,omitempty"`
+	// Paid is the sum of the payments of the bill, the part paid over the
+	// amount is recorded as Credit of the user.
+	Paid      int               `json:"paid,omitempty"`
+	Credit    int               `json:"credit,omitempty"`
+	Breakdown *PriceBreakdown   `json:"breakdown,omitempty"`
+	Items     []TransactionCart `json:"items,omitempty"`
+}
+
+// PriceBreakdown tells how the amount of a bill is computed, Total is the
+// amount to pay. Fee is charged by the payment provider.
+type PriceBreakdown struct {
+	Lines     []PriceLine     `json:"lines,omitempty"`
+	Subtotal  int             `json:"subtotal"`
+	Discounts []PriceDiscount `json:"discounts,omitempty"`
+	Discount  int             `json:"discount"`
+	Tax       int             `json:"tax"`
+	Fee       int             `json:"fee"`
+	Total     int             `json:"total"`
+}
+
+type PriceLine struct {
+	ProductUuid string `json:"product_uuid,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Price       int    `json:"price"`
+	Qty         int    `json:"qty"`
+	Total       int    `json:"total"`
+}
+
+type PriceDiscount struct {
+	Code        string `json:"code,omitempty"`
+	Description string `json:"description,omitempty"`
+	Amount      int    `json:"amount"`
 }
 
 type TransactionCart struct {
@@ -45,33 +77,58 @@ type TransactionPayment struct {
 	Transaction     Transaction `json:"transaction,omitempty"`
 }
 
+// TransactionRefund gives back part or all of the payment of a bill, the
+// stock of the Items is restored. TransactionStatus is the status of the bill
+// once refunded.
+type TransactionRefund struct {
+	Uuid              string                  `json:"uuid,omitempty"`
+	TransactionUuid   string                  `json:"transaction_uuid,omitempty"`
+	OrderUuid         string                  `json:"order_uuid,omitempty"`
+	Amount            int                     `json:"amount,omitempty"`
+	Reason            string                  `json:"reason,omitempty"`
+	Items             []TransactionRefundItem `json:"items,omitempty"`
+	TransactionStatus string                  `json:"transaction_status,omitempty"`
+	Created           time.Time               `json:"created,omitempty"`
+}
+
+type TransactionRefundItem struct {
+	ProductUuid string `json:"product_uuid,omitempty"`
+	Qty         int    `json:"qty,omitempty"`
+}
+
 type PaymentFlag struct {
 	Transaction Transaction        `json:"transaction,omitempty"`
 	Payment     TransactionPayment `json:"payment,omitempty"`
 }
 
+// status of a transaction
+const STATUS_UNPAID = "UNPAID"
+const STATUS_PARTIALLY_PAID = "PARTIALLY_PAID"
+const STATUS_PAID = "PAID"
+const STATUS_EXPIRED = "EXPIRED"
+const STATUS_CANCELLED = "CANCELLED"
+const STATUS_REFUNDED = "REFUNDED"
+
 var typeStatusEnum = graphql.NewEnum(graphql.EnumConfig{
 	Name: "Status",
 	Values: graphql.EnumValueConfigMap{
-		"UNPAID": &graphql.EnumValueConfig{
-			Value: "UNPAID",
+		STATUS_UNPAID: &graphql.EnumValueConfig{
+			Value: STATUS_UNPAID,
 		},
-		"PAID": &graphql.EnumValueConfig{
-			Value: "PAID",
+		STATUS_PARTIALLY_PAID: &graphql.EnumValueConfig{
+			Value: STATUS_PARTIALLY_PAID,
 		},
-	},
-})
-var typePaymentMethod = graphql.NewEnum(graphql.EnumConfig{
-	Name: "PaymentMethod",
-	Values: graphql.EnumValueConfigMap{
-		"BCA": &graphql.EnumValueConfig{
-			Value: "BCA",
+		STATUS_PAID: &graphql.EnumValueConfig{
+			Value: STATUS_PAID,
 		},
-		"BNI": &graphql.EnumValueConfig{
-			Value: "BNI",
+		STATUS_EXPIRED: &graphql.EnumValueConfig{
+			Value: STATUS_EXPIRED,
 		},
-		"BRI": &graphql.EnumValueConfig{
-			Value: "BRI",
+		STATUS_CANCELLED: &graphql.EnumValueConfig{
+			Value: STATUS_CANCELLED,
+		},
+		STATUS_REFUNDED: &graphql.EnumValueConfig{
+			Value: STATUS_REFUNDED,
 		},
 	},
 })
@@ -84,12 +141,19 @@ func transactionType(name string, field []string) *graphql.Object {
 			Type: typeStatusEnum,
 		},
 		"payment_method": &graphql.Field{
-			Type: typePaymentMethod,
+			Type: typePaymentMethod(),
 		},
 		"amount":          typeInt,
 		"virtual_account": typeString,
+		"voucher_code":    typeString,
+		"order_uuid":      typeString,
+		"paid":            typeInt,
+		"credit":          typeInt,
 		"created":         typeDatetime,
 		"expired":         typeDatetime,
+		"breakdown": &graphql.Field{
+			Type: typePriceBreakdown,
+		},
 	}
 	allowedField := getAllowedField(allField, field)
 	return graphql.NewObject(
@@ -100,6 +164,39 @@ func transactionType(name string, field []string) *graphql.Object {
 	)
 }
 
+var typePriceBreakdown = graphql.NewObject(graphql.ObjectConfig{
+	Name: "price_breakdown",
+	Fields: graphql.Fields{
+		"lines": &graphql.Field{
+			Type: graphql.NewList(graphql.NewObject(graphql.ObjectConfig{
+				Name: "price_line",
+				Fields: graphql.Fields{
+					"product_uuid": typeString,
+					"name":         typeString,
+					"price":        typeInt,
+					"qty":          typeInt,
+					"total":        typeInt,
+				},
+			})),
+		},
+		"subtotal": typeInt,
+		"discounts": &graphql.Field{
+			Type: graphql.NewList(graphql.NewObject(graphql.ObjectConfig{
+				Name: "price_discount",
+				Fields: graphql.Fields{
+					"code":        typeString,
+					"description": typeString,
+					"amount":      typeInt,
+				},
+			})),
+		},
+		"discount": typeInt,
+		"tax":      typeInt,
+		"fee":      typeInt,
+		"total":    typeInt,
+	},
+})
+
 func transactionCartType(name string, field []string) *graphql.Object {
 	allField := map[string]*graphql.Field{
 		"uuid":         typeString,
@@ -143,9 +240,15 @@ func (t Transaction) cartsQuery() *graphql.Field {
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 			user := t.ctx.Locals("user").(User)
 			correlationId := t.ctx.Locals("uuid").(string)
-			carts := t.r.Transaction().ListCart(p.Context, correlationId, user.Uuid)
+			carts, err := t.r.Transaction().ListCart(p.Context, correlationId, user.Uuid)
+			if err != nil {
+				return nil, rpcError(err)
+			}
 			for i, cart := range carts {
-				_, product := t.r.Product().DetailProduct(p.Context, correlationId, cart.ProductUuid)
+				product, err := t.r.Product().DetailProduct(p.Context, correlationId, cart.ProductUuid)
+				if err != nil {
+					return nil, rpcError(err)
+				}
 				carts[i].Product = product
 			}
 			return carts, nil
@@ -162,11 +265,33 @@ func (t Transaction) billingQuery() *graphql.Field {
 	billing.AddFieldConfig("items", &graphql.Field{
 		Type: graphql.NewList(item),
 	})
+	billing.AddFieldConfig("order", &graphql.Field{
+		Type:        orderType("billing_order", []string{}),
+		Description: "Pesanan yang dibuat setelah tagihan lunas",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			trx := p.Source.(Transaction)
+			if trx.OrderUuid == "" {
+				return nil, nil
+			}
+			correlationId := t.ctx.Locals("uuid").(string)
+			order, err := t.r.Order().DetailOrder(p.Context, correlationId, Order{
+				Uuid:     trx.OrderUuid,
+				UserUuid: trx.UserUuid,
+			})
+			if IsNotFound(err) {
+				return nil, nil
+			}
+			if err != nil {
+				return nil, rpcError(err)
+			}
+			return order, nil
+		},
+	})
 	return &graphql.Field{
 		Type: billing,
 		Args: graphql.FieldConfigArgument{
 			"payment_method": &graphql.ArgumentConfig{
-				Type: graphql.NewNonNull(typePaymentMethod),
+				Type: graphql.NewNonNull(typePaymentMethod()),
 			},
 			"virtual_account": &graphql.ArgumentConfig{
 				Type: graphql.NewNonNull(graphql.String),
@@ -183,13 +308,22 @@ func (t Transaction) billingQuery() *graphql.Field {
 				PaymentMethod:  paymentMethod,
 				VirtualAccount: virtualAccount,
 			}
-			trx = t.r.Transaction().DetailBilling(p.Context, correlationId, trx)
-			trx.Items = t.r.Transaction().ListTransactionItem(p.Context, correlationId, trx.Uuid)
-			for i, it := range trx.Items {
-				_, trx.Items[i].Product = t.r.Product().DetailProduct(p.Context, correlationId, it.ProductUuid)
+			trx, err := t.r.Transaction().DetailBilling(p.Context, correlationId, trx)
+			if IsNotFound(err) {
+				return nil, newGraphqlError(CODE_NOT_FOUND, "tagihan tidak ditemukan")
+			}
+			if err != nil {
+				return nil, rpcError(err)
 			}
-			if trx.Uuid == "" {
-				return nil, errors.New("tagihan tidak ditemukan")
+			trx.Items, err = t.r.Transaction().ListTransactionItem(p.Context, correlationId, trx.Uuid)
+			if err != nil {
+				return nil, rpcError(err)
+			}
+			for i, it := range trx.Items {
+				trx.Items[i].Product, err = t.r.Product().DetailProduct(p.Context, correlationId, it.ProductUuid)
+				if err != nil {
+					return nil, rpcError(err)
+				}
 			}
 			return trx, nil
 		},
@@ -213,9 +347,12 @@ func (t Transaction) addToCartMutation() *graphql.Field {
 			qty := p.Args["qty"].(int)
 			correlationId := t.ctx.Locals("uuid").(string)
 			user := t.ctx.Locals("user").(User)
-			status, product := t.r.Product().DetailProduct(context.Background(), correlationId, productUuid)
-			if !status {
-				return nil, errors.New("produk tidak tersedia")
+			product, err := t.r.Product().DetailProduct(p.Context, correlationId, productUuid)
+			if IsNotFound(err) {
+				return nil, newGraphqlError(CODE_NOT_FOUND, "produk tidak tersedia")
+			}
+			if err != nil {
+				return nil, rpcError(err)
 			}
 
 			if qty > product.Stock {
@@ -229,12 +366,18 @@ func (t Transaction) addToCartMutation() *graphql.Field {
 				UserUuid:    user.Uuid,
 				Qty:         qty,
 			}
-			checkCart := t.r.Transaction().CheckProductInCart(p.Context, correlationId, cart)
-			if checkCart.ProductUuid == cart.ProductUuid {
-				return nil, errors.New("produk sudah ada dalam keranjang")
+			_, err = t.r.Transaction().CheckProductInCart(p.Context, correlationId, cart)
+			if err == nil {
+				return nil, newGraphqlError(CODE_DUPLICATE, "produk sudah ada dalam keranjang")
+			}
+			if !IsNotFound(err) {
+				return nil, rpcError(err)
 			}
 
-			insertedCart := t.r.Transaction().InsertProductToCart(p.Context, correlationId, cart)
+			insertedCart, err := t.r.Transaction().InsertProductToCart(p.Context, correlationId, cart)
+			if err != nil {
+				return nil, rpcError(err)
+			}
 			insertedCart.Product = product
 			return insertedCart, nil
 		},
@@ -258,9 +401,12 @@ func (t Transaction) updateCartMutation() *graphql.Field {
 			qty := p.Args["qty"].(int)
 			correlationId := t.ctx.Locals("uuid").(string)
 			user := t.ctx.Locals("user").(User)
-			status, product := t.r.Product().DetailProduct(context.Background(), correlationId, productUuid)
-			if !status {
-				return nil, errors.New("produk tidak tersedia")
+			product, err := t.r.Product().DetailProduct(p.Context, correlationId, productUuid)
+			if IsNotFound(err) {
+				return nil, newGraphqlError(CODE_NOT_FOUND, "produk tidak tersedia")
+			}
+			if err != nil {
+				return nil, rpcError(err)
 			}
 
 			if qty > product.Stock {
@@ -274,14 +420,17 @@ func (t Transaction) updateCartMutation() *graphql.Field {
 				UserUuid:    user.Uuid,
 				Qty:         qty,
 			}
-			checkCart := t.r.Transaction().CheckProductInCart(p.Context, correlationId, cart)
-			if checkCart.ProductUuid == "" {
-				return nil, errors.New("produk tidak ada dalam keranjang")
+			_, err = t.r.Transaction().CheckProductInCart(p.Context, correlationId, cart)
+			if IsNotFound(err) {
+				return nil, newGraphqlError(CODE_NOT_FOUND, "produk tidak ada dalam keranjang")
+			}
+			if err != nil {
+				return nil, rpcError(err)
 			}
 
-			updatedCart := t.r.Transaction().UpdateCart(p.Context, correlationId, cart)
-			if updatedCart.Uuid == "" {
-				return nil, errors.New("internal server error")
+			updatedCart, err := t.r.Transaction().UpdateCart(p.Context, correlationId, cart)
+			if err != nil {
+				return nil, rpcError(err)
 			}
 			return updatedCart, nil
 		},
@@ -294,36 +443,221 @@ func (t Transaction) createBillingMutation() *graphql.Field {
 		Description: "Pembuatan tagihan",
 		Args: graphql.FieldConfigArgument{
 			"payment_method": &graphql.ArgumentConfig{
-				Type: graphql.NewNonNull(typePaymentMethod),
+				Type: graphql.NewNonNull(typePaymentMethod()),
+			},
+			"voucher_code": &graphql.ArgumentConfig{
+				Type:        graphql.String,
+				Description: "Voucher yang dipakai, default voucher yang dipasang dengan applyVoucher",
 			},
 		},
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 			correlationId := t.ctx.Locals("uuid").(string)
 			user := t.ctx.Locals("user").(User)
-			carts := t.r.Transaction().ListCart(p.Context, correlationId, user.Uuid)
+			voucherCode, _ := p.Args["voucher_code"].(string)
+			carts, err := t.r.Transaction().ListCart(p.Context, correlationId, user.Uuid)
+			if err != nil {
+				return nil, rpcError(err)
+			}
 			if len(carts) == 0 {
 				return nil, errors.New("keranjang masih kosong")
 			}
-			amount := 0
-			for _, cart := range carts {
-				_, product := t.r.Product().DetailProduct(p.Context, correlationId, cart.ProductUuid)
-				amount = amount + product.Price
-			}
 
+			// jumlah tagihan dihitung oleh transaction-service
 			transaction := Transaction{
 				UserUuid:      user.Uuid,
-				Amount:        amount,
 				PaymentMethod: p.Args["payment_method"].(string),
+				VoucherCode:   voucherCode,
+			}
+			trx, err := t.r.Transaction().CreateBilling(p.Context, correlationId, transaction)
+			if err != nil {
+				return nil, voucherError(err)
+			}
+			return trx, nil
+		},
+	}
+}
+
+func (t Transaction) applyVoucherMutation() *graphql.Field {
+	return &graphql.Field{
+		Type:        transactionType("applyVoucher", []string{"amount", "voucher_code", "breakdown"}),
+		Description: "Memasang voucher pada keranjang, kosongkan code untuk melepas voucher",
+		Args: graphql.FieldConfigArgument{
+			"code": &graphql.ArgumentConfig{
+				Type: graphql.String,
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			correlationId := t.ctx.Locals("uuid").(string)
+			user := t.ctx.Locals("user").(User)
+			code, _ := p.Args["code"].(string)
+			carts, err := t.r.Transaction().ListCart(p.Context, correlationId, user.Uuid)
+			if err != nil {
+				return nil, rpcError(err)
 			}
-			trx := t.r.Transaction().CreateBilling(p.Context, correlationId, transaction)
-			if trx.Uuid == "" {
-				return nil, errors.New("internal server error")
+			if len(carts) == 0 {
+				return nil, errors.New("keranjang masih kosong")
+			}
+
+			transaction := Transaction{
+				UserUuid:    user.Uuid,
+				VoucherCode: code,
+			}
+			trx, err := t.r.Transaction().ApplyVoucher(p.Context, correlationId, transaction)
+			if err != nil {
+				return nil, voucherError(err)
 			}
 			return trx, nil
 		},
 	}
 }
 
+func (t Transaction) cancelBillingMutation() *graphql.Field {
+	return &graphql.Field{
+		Type:        transactionType("cancelBilling", []string{}),
+		Description: "Membatalkan tagihan yang belum dibayar",
+		Args: graphql.FieldConfigArgument{
+			"payment_method": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(typePaymentMethod()),
+			},
+			"virtual_account": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			correlationId := t.ctx.Locals("uuid").(string)
+			user := t.ctx.Locals("user").(User)
+			trx := Transaction{
+				UserUuid:       user.Uuid,
+				PaymentMethod:  p.Args["payment_method"].(string),
+				VirtualAccount: p.Args["virtual_account"].(string),
+			}
+			trx, err := t.r.Transaction().CancelBilling(p.Context, correlationId, trx)
+			if IsNotFound(err) {
+				return nil, newGraphqlError(CODE_NOT_FOUND, "tagihan tidak ditemukan")
+			}
+			if ErrorCode(err) == CODE_INVALID_REQUEST {
+				return nil, errors.New("hanya tagihan yang belum dibayar yang dapat dibatalkan")
+			}
+			if err != nil {
+				return nil, rpcError(err)
+			}
+			return trx, nil
+		},
+	}
+}
+
+var typeRefundItemInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "refund_item_input",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"product_uuid": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.String),
+		},
+		"qty": &graphql.InputObjectFieldConfig{
+			Type: graphql.NewNonNull(graphql.Int),
+		},
+	},
+})
+
+func transactionRefundType(name string, field []string) *graphql.Object {
+	allField := map[string]*graphql.Field{
+		"uuid":             typeString,
+		"transaction_uuid": typeString,
+		"order_uuid":       typeString,
+		"amount":           typeInt,
+		"reason":           typeString,
+		"items": &graphql.Field{
+			Type: graphql.NewList(transactionCartType(name+"_item", []string{"product_uuid", "qty"})),
+		},
+		"transaction_status": &graphql.Field{
+			Type: typeStatusEnum,
+		},
+		"created": typeDatetime,
+	}
+	allowedField := getAllowedField(allField, field)
+	return graphql.NewObject(
+		graphql.ObjectConfig{
+			Name:   name,
+			Fields: *allowedField,
+		},
+	)
+}
+
+// refundMutation is meant for the operators, the request has to carry the
+// key in OPS_API_KEY in the X-Ops-Key header.
+func (t Transaction) refundMutation() *graphql.Field {
+	return &graphql.Field{
+		Type:        transactionRefundType("refund", []string{}),
+		Description: "Refund sebagian atau seluruh pembayaran tagihan, amount kosong berarti seluruh sisa pembayaran",
+		Args: graphql.FieldConfigArgument{
+			"payment_method": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(typePaymentMethod()),
+			},
+			"virtual_account": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"reason": &graphql.ArgumentConfig{
+				Type: graphql.NewNonNull(graphql.String),
+			},
+			"amount": &graphql.ArgumentConfig{
+				Type: graphql.Int,
+			},
+			"items": &graphql.ArgumentConfig{
+				Type:        graphql.NewList(graphql.NewNonNull(typeRefundItemInput)),
+				Description: "Produk yang stoknya dikembalikan",
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			correlationId := t.ctx.Locals("uuid").(string)
+			if !isOperator(t.ctx) {
+				return nil, newGraphqlError(CODE_INVALID_REQUEST, "refund hanya dapat dilakukan oleh operator")
+			}
+			trx := Transaction{
+				PaymentMethod:  p.Args["payment_method"].(string),
+				VirtualAccount: p.Args["virtual_account"].(string),
+			}
+			trx, err := t.r.Transaction().DetailBilling(p.Context, correlationId, trx)
+			if IsNotFound(err) {
+				return nil, newGraphqlError(CODE_NOT_FOUND, "tagihan tidak ditemukan")
+			}
+			if err != nil {
+				return nil, rpcError(err)
+			}
+			if trx.Status == STATUS_PARTIALLY_PAID {
+				return nil, newGraphqlError(CODE_INVALID_REQUEST, "tagihan yang baru dibayar sebagian tidak dapat direfund, pembayarannya dicatat sebagai kredit user saat tagihan kedaluwarsa")
+			}
+			if trx.Status != STATUS_PAID {
+				return nil, errors.New("hanya tagihan yang sudah dibayar yang dapat direfund")
+			}
+
+			refund := TransactionRefund{
+				TransactionUuid: trx.Uuid,
+				Reason:          p.Args["reason"].(string),
+			}
+			refund.Amount, _ = p.Args["amount"].(int)
+			items, _ := p.Args["items"].([]interface{})
+			for _, i := range items {
+				item := i.(map[string]interface{})
+				refund.Items = append(refund.Items, TransactionRefundItem{
+					ProductUuid: item["product_uuid"].(string),
+					Qty:         item["qty"].(int),
+				})
+			}
+			refund, err = t.r.Transaction().Refund(p.Context, correlationId, refund)
+			if err != nil {
+				var rErr *RPCError
+				if errors.As(err, &rErr) && rErr.Code == CODE_INVALID_REQUEST {
+					// the reason is safe to show to the operators
+					return nil, newGraphqlError(rErr.Code, rErr.Message)
+				}
+				return nil, rpcError(err)
+			}
+			return refund, nil
+		},
+	}
+}
+
+// paymentMutation flags a payment received outside the webhook, it is meant
+// for the operators like refundMutation.
 func (t Transaction) paymentMutation() *graphql.Field {
 	payment := transactionPaymentType("payment", []string{})
 	payment.AddFieldConfig("transaction", &graphql.Field{
@@ -331,10 +665,10 @@ func (t Transaction) paymentMutation() *graphql.Field {
 	})
 	return &graphql.Field{
 		Type:        payment,
-		Description: "Proses flag transaction",
+		Description: "Proses flag transaction, tagihan dapat dibayar beberapa kali hingga lunas",
 		Args: graphql.FieldConfigArgument{
 			"payment_method": &graphql.ArgumentConfig{
-				Type: graphql.NewNonNull(typePaymentMethod),
+				Type: graphql.NewNonNull(typePaymentMethod()),
 			},
 			"virtual_account": &graphql.ArgumentConfig{
 				Type: graphql.NewNonNull(graphql.String),
@@ -351,27 +685,34 @@ func (t Transaction) paymentMutation() *graphql.Field {
 		},
 		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
 			correlationId := t.ctx.Locals("uuid").(string)
-			user := t.ctx.Locals("user").(User)
+			if !isOperator(t.ctx) {
+				return nil, newGraphqlError(CODE_INVALID_REQUEST, "pembayaran hanya dapat ditandai oleh operator")
+			}
 			paymentMethod := p.Args["payment_method"].(string)
 			virtualAccount := p.Args["virtual_account"].(string)
 			reference := p.Args["reference"].(string)
 			amount := p.Args["amount"].(int)
 			paymentDatetime := p.Args["payment_datetime"].(string)
 			trx := Transaction{
-				UserUuid:       user.Uuid,
 				PaymentMethod:  paymentMethod,
 				VirtualAccount: virtualAccount,
 			}
-			trx = t.r.Transaction().DetailBilling(p.Context, correlationId, trx)
-			if trx.Uuid == "" {
-				return nil, errors.New("tagihan tidak ditemukan")
+			trx, err := t.r.Transaction().DetailBilling(p.Context, correlationId, trx)
+			if IsNotFound(err) {
+				return nil, newGraphqlError(CODE_NOT_FOUND, "tagihan tidak ditemukan")
+			}
+			if err != nil {
+				return nil, rpcError(err)
 			}
-			if trx.Status == "PAID" {
+			if trx.Status == STATUS_PAID {
 				return nil, errors.New("tagihan sudah terbayar")
 			}
+			if trx.Status == STATUS_EXPIRED || time.Now().After(trx.Expired) {
+				return nil, errors.New("tagihan sudah kedaluwarsa")
+			}
 
-			if amount < trx.Amount {
-				return nil, errors.New("uang anda kurang untuk melakukan pembayaran")
+			if amount <= 0 {
+				return nil, errors.New("jumlah pembayaran harus lebih dari 0")
 			}
 			paymentDatetimeAsTime, err := time.Parse("2006-01-02 15:04:05", paymentDatetime)
 			if err != nil {
@@ -387,38 +728,19 @@ func (t Transaction) paymentMutation() *graphql.Field {
 				},
 			}
 
-			// implementasi saga harusnya disini
-			pFlagRes := t.r.Transaction().ProcessPayment(p.Context, correlationId, paymentFlag)
-			// proses order
-			order := Order{
-				UserUuid: user.Uuid,
-				Amount:   trx.Amount,
-			}
-			transactionItem := t.r.Transaction().ListTransactionItem(p.Context, correlationId, trx.Uuid)
-			for _, i := range transactionItem {
-				// decrement stock
-				_, product := t.r.Product().DetailProduct(p.Context, correlationId, i.ProductUuid)
-				product.Stock = i.Qty
-				go t.r.Product().DecrementStock(p.Context, correlationId, product)
-				or := OrderItem{
-					ProductUuid: i.ProductUuid,
-					Name:        product.Name,
-					Description: product.Description,
-					Price:       product.Price,
-					Qty:         i.Qty,
-				}
-				order.Items = append(order.Items, or)
+			// status tagihan, stok dan pesanan diproses oleh saga di transaction-service
+			pFlagRes, err := t.r.Transaction().ProcessPayment(p.Context, correlationId, paymentFlag)
+			if IsDuplicate(err) {
+				return nil, errors.New("tagihan sudah terbayar")
 			}
-			order = t.r.Order().ProcessOrder(p.Context, correlationId, order)
-			if order.Uuid == "" {
-				return nil, errors.New("internal server error")
+			if IsExpired(err) {
+				return nil, errors.New("tagihan sudah kedaluwarsa")
 			}
-
-			if pFlagRes.Payment.Uuid == "" {
-				return nil, errors.New("internal server error")
+			if err != nil {
+				return nil, rpcError(err)
 			}
 
-			pFlagRes.Payment.Transaction = trx
+			pFlagRes.Payment.Transaction = pFlagRes.Transaction
 			return pFlagRes.Payment, nil
 		},
 	}
@@ -449,6 +771,9 @@ func (t Transaction) Context(c *fiber.Ctx) Schema {
 			"addToCart":     transaction.addToCartMutation(),
 			"updateCart":    transaction.updateCartMutation(),
 			"createBilling": transaction.createBillingMutation(),
+			"applyVoucher":  transaction.applyVoucherMutation(),
+			"cancelBilling": transaction.cancelBillingMutation(),
+			"refund":        transaction.refundMutation(),
 			"payment":       transaction.paymentMutation(),
 		},
 	})
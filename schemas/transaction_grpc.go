@@ -0,0 +1,106 @@
+package schemas
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"wahyuade.com/simple-e-commerce/pb"
+)
+
+type transaction_grpc struct {
+	client pb.TransactionServiceClient
+}
+
+func newTransactionGrpc(conn *grpc.ClientConn) transaction_grpc {
+	return transaction_grpc{
+		client: pb.NewTransactionServiceClient(conn),
+	}
+}
+
+func (t transaction_grpc) CheckProductInCart(ctx context.Context, correlationId string, cart TransactionCart) (TransactionCart, error) {
+	resp, err := t.client.CheckProductInCart(withCorrelationId(ctx, correlationId), TransactionCartToPB(cart))
+	if err != nil {
+		return TransactionCart{}, grpcError(CHECK_PRODUCT_IN_CART_QUEUE, correlationId, err)
+	}
+	return TransactionCartFromPB(resp), nil
+}
+
+func (t transaction_grpc) InsertProductToCart(ctx context.Context, correlationId string, cart TransactionCart) (TransactionCart, error) {
+	resp, err := t.client.InsertProductToCart(withCorrelationId(ctx, correlationId), TransactionCartToPB(cart))
+	if err != nil {
+		return TransactionCart{}, grpcError(INSERT_PRODUCT_TO_CART_QUEUE, correlationId, err)
+	}
+	return TransactionCartFromPB(resp), nil
+}
+
+func (t transaction_grpc) ListCart(ctx context.Context, correlationId string, userUuid string) ([]TransactionCart, error) {
+	resp, err := t.client.ListCart(withCorrelationId(ctx, correlationId), &pb.UserRequest{UserUuid: userUuid})
+	if err != nil {
+		return nil, grpcError(LIST_CART_QUEUE, correlationId, err)
+	}
+	return TransactionCartListFromPB(resp), nil
+}
+
+func (t transaction_grpc) UpdateCart(ctx context.Context, correlationId string, cart TransactionCart) (TransactionCart, error) {
+	resp, err := t.client.UpdateCart(withCorrelationId(ctx, correlationId), TransactionCartToPB(cart))
+	if err != nil {
+		return TransactionCart{}, grpcError(UPDATE_CART_QUEUE, correlationId, err)
+	}
+	return TransactionCartFromPB(resp), nil
+}
+
+func (t transaction_grpc) CreateBilling(ctx context.Context, correlationId string, trx Transaction) (Transaction, error) {
+	resp, err := t.client.CreateBilling(withCorrelationId(ctx, correlationId), TransactionToPB(trx))
+	if err != nil {
+		return Transaction{}, grpcError(CREATE_BILLING_QUEUE, correlationId, err)
+	}
+	return TransactionFromPB(resp), nil
+}
+
+func (t transaction_grpc) ListTransactionItem(ctx context.Context, correlationId string, transactionUuid string) ([]TransactionCart, error) {
+	resp, err := t.client.ListTransactionItem(withCorrelationId(ctx, correlationId), &pb.TransactionRequest{TransactionUuid: transactionUuid})
+	if err != nil {
+		return nil, grpcError(LIST_TRANSACTION_ITEM_QUEUE, correlationId, err)
+	}
+	return TransactionCartListFromPB(resp), nil
+}
+
+func (t transaction_grpc) DetailBilling(ctx context.Context, correlationId string, trx Transaction) (Transaction, error) {
+	resp, err := t.client.DetailBilling(withCorrelationId(ctx, correlationId), TransactionToPB(trx))
+	if err != nil {
+		return Transaction{}, grpcError(DETAIL_BILLING_QUEUE, correlationId, err)
+	}
+	return TransactionFromPB(resp), nil
+}
+
+func (t transaction_grpc) ProcessPayment(ctx context.Context, correlationId string, pF PaymentFlag) (PaymentFlag, error) {
+	resp, err := t.client.ProcessPayment(withCorrelationId(ctx, correlationId), PaymentFlagToPB(pF))
+	if err != nil {
+		return PaymentFlag{}, grpcError(PROCESS_PAYMENT_QUEUE, correlationId, err)
+	}
+	return PaymentFlagFromPB(resp), nil
+}
+
+func (t transaction_grpc) ApplyVoucher(ctx context.Context, correlationId string, trx Transaction) (Transaction, error) {
+	resp, err := t.client.ApplyVoucher(withCorrelationId(ctx, correlationId), TransactionToPB(trx))
+	if err != nil {
+		return Transaction{}, grpcError(APPLY_VOUCHER_QUEUE, correlationId, err)
+	}
+	return TransactionFromPB(resp), nil
+}
+
+func (t transaction_grpc) CancelBilling(ctx context.Context, correlationId string, trx Transaction) (Transaction, error) {
+	resp, err := t.client.CancelBilling(withCorrelationId(ctx, correlationId), TransactionToPB(trx))
+	if err != nil {
+		return Transaction{}, grpcError(CANCEL_BILLING_QUEUE, correlationId, err)
+	}
+	return TransactionFromPB(resp), nil
+}
+
+func (t transaction_grpc) Refund(ctx context.Context, correlationId string, refund TransactionRefund) (TransactionRefund, error) {
+	resp, err := t.client.Refund(withCorrelationId(ctx, correlationId), TransactionRefundToPB(refund))
+	if err != nil {
+		return TransactionRefund{}, grpcError(REFUND_QUEUE, correlationId, err)
+	}
+	return TransactionRefundFromPB(resp), nil
+}
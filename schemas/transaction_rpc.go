@@ -4,125 +4,145 @@ import (
 	"context"
 	"encoding/json"
 
-	"github.com/rabbitmq/amqp091-go"
+	"wahyuade.com/simple-e-commerce/broker"
 )
 
 const TOPIC_TRANSACTION = "transaction-service"
 
 const CHECK_PRODUCT_IN_CART_QUEUE = "checkProductInCart"
-const CHECK_PRODUCT_IN_CART_RSP_QUEUE = "checkProductInCartRsp"
 const INSERT_PRODUCT_TO_CART_QUEUE = "insertProductToCart"
-const INSERT_PRODUCT_TO_CART_RSP_QUEUE = "insertProductToCartRsp"
 const LIST_CART_QUEUE = "listCart"
-const LIST_CART_RSP_QUEUE = "listCartRsp"
 const UPDATE_CART_QUEUE = "updateCart"
-const UPDATE_CART_RSP_QUEUE = "updateCartRsp"
 const CREATE_BILLING_QUEUE = "createBilling"
-const CREATE_BILLING_RSP_QUEUE = "createBillingRsp"
 const LIST_TRANSACTION_ITEM_QUEUE = "listTransactionItem"
-const LIST_TRANSACTION_ITEM_RSP_QUEUE = "listTransactionItemRsp"
 const DETAIL_BILLING_QUEUE = "detailBilling"
-const DETAIL_BILLING_RSP_QUEUE = "detailBillingRsp"
 const PROCESS_PAYMENT_QUEUE = "processPayment"
-const PROCESS_PAYMENT_RSP_QUEUE = "processPaymentRsp"
+const APPLY_VOUCHER_QUEUE = "applyVoucher"
+const CANCEL_BILLING_QUEUE = "cancelBilling"
+const REFUND_QUEUE = "refund"
 
 type TransactionRPC interface {
-	CheckProductInCart(ctx context.Context, correlationId string, cart TransactionCart) TransactionCart
-	InsertProductToCart(ctx context.Context, correlationId string, cart TransactionCart) TransactionCart
-	ListCart(ctx context.Context, correlationId, userUuid string) []TransactionCart
-	UpdateCart(ctx context.Context, correlationId string, cart TransactionCart) TransactionCart
-	CreateBilling(ctx context.Context, correlationId string, trx Transaction) Transaction
-	ListTransactionItem(ctx context.Context, correlationId, transactionUuid string) []TransactionCart
-	DetailBilling(ctx context.Context, correlationId string, trx Transaction) Transaction
-	ProcessPayment(ctx context.Context, correlationId string, pF PaymentFlag) PaymentFlag
+	CheckProductInCart(ctx context.Context, correlationId string, cart TransactionCart) (TransactionCart, error)
+	InsertProductToCart(ctx context.Context, correlationId string, cart TransactionCart) (TransactionCart, error)
+	ListCart(ctx context.Context, correlationId, userUuid string) ([]TransactionCart, error)
+	UpdateCart(ctx context.Context, correlationId string, cart TransactionCart) (TransactionCart, error)
+	CreateBilling(ctx context.Context, correlationId string, trx Transaction) (Transaction, error)
+	ListTransactionItem(ctx context.Context, correlationId, transactionUuid string) ([]TransactionCart, error)
+	DetailBilling(ctx context.Context, correlationId string, trx Transaction) (Transaction, error)
+	ProcessPayment(ctx context.Context, correlationId string, pF PaymentFlag) (PaymentFlag, error)
+	ApplyVoucher(ctx context.Context, correlationId string, trx Transaction) (Transaction, error)
+	CancelBilling(ctx context.Context, correlationId string, trx Transaction) (Transaction, error)
+	Refund(ctx context.Context, correlationId string, refund TransactionRefund) (TransactionRefund, error)
 }
 
 type transaction_rpc struct {
-	conn *amqp091.Connection
+	t broker.Transport
 }
 
-func (t transaction_rpc) CheckProductInCart(ctx context.Context, correlationId string, cart TransactionCart) (txCart TransactionCart) {
+func (t transaction_rpc) CheckProductInCart(ctx context.Context, correlationId string, cart TransactionCart) (txCart TransactionCart, err error) {
 	requestPayload, _ := json.Marshal(cart)
-	ch := newChannel(t.conn, TOPIC_TRANSACTION)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_TRANSACTION, CHECK_PRODUCT_IN_CART_QUEUE, CHECK_PRODUCT_IN_CART_RSP_QUEUE, correlationId, requestPayload)
-	if response != nil {
-		json.Unmarshal(response, &txCart)
+	response, err := waitForResponse(ctx, t.t, TOPIC_TRANSACTION, CHECK_PRODUCT_IN_CART_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return txCart, err
 	}
-	return txCart
+	err = decodeReply(CHECK_PRODUCT_IN_CART_QUEUE, response, &txCart)
+	return txCart, err
 }
 
-func (t transaction_rpc) InsertProductToCart(ctx context.Context, correlationId string, cart TransactionCart) (txCart TransactionCart) {
+func (t transaction_rpc) InsertProductToCart(ctx context.Context, correlationId string, cart TransactionCart) (txCart TransactionCart, err error) {
 	requestPayload, _ := json.Marshal(cart)
-	ch := newChannel(t.conn, TOPIC_TRANSACTION)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_TRANSACTION, INSERT_PRODUCT_TO_CART_QUEUE, INSERT_PRODUCT_TO_CART_RSP_QUEUE, correlationId, requestPayload)
-	if response != nil {
-		json.Unmarshal(response, &txCart)
+	response, err := waitForResponse(ctx, t.t, TOPIC_TRANSACTION, INSERT_PRODUCT_TO_CART_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return txCart, err
 	}
-	return txCart
+	err = decodeReply(INSERT_PRODUCT_TO_CART_QUEUE, response, &txCart)
+	return txCart, err
 }
 
-func (t transaction_rpc) UpdateCart(ctx context.Context, correlationId string, cart TransactionCart) (txCart TransactionCart) {
+func (t transaction_rpc) UpdateCart(ctx context.Context, correlationId string, cart TransactionCart) (txCart TransactionCart, err error) {
 	requestPayload, _ := json.Marshal(cart)
-	ch := newChannel(t.conn, TOPIC_TRANSACTION)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_TRANSACTION, UPDATE_CART_QUEUE, UPDATE_CART_RSP_QUEUE, correlationId, requestPayload)
-	if response != nil {
-		json.Unmarshal(response, &txCart)
+	response, err := waitForResponse(ctx, t.t, TOPIC_TRANSACTION, UPDATE_CART_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return txCart, err
 	}
-	return txCart
+	err = decodeReply(UPDATE_CART_QUEUE, response, &txCart)
+	return txCart, err
 }
 
-func (t transaction_rpc) ListCart(ctx context.Context, correlationId, userUuid string) (carts []TransactionCart) {
-	ch := newChannel(t.conn, TOPIC_TRANSACTION)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_TRANSACTION, LIST_CART_QUEUE, LIST_CART_RSP_QUEUE, correlationId, []byte(userUuid))
-	if response != nil {
-		json.Unmarshal(response, &carts)
+func (t transaction_rpc) ListCart(ctx context.Context, correlationId, userUuid string) (carts []TransactionCart, err error) {
+	response, err := waitForResponse(ctx, t.t, TOPIC_TRANSACTION, LIST_CART_QUEUE, correlationId, []byte(userUuid))
+	if err != nil {
+		return carts, err
 	}
-	return carts
+	err = decodeReply(LIST_CART_QUEUE, response, &carts)
+	return carts, err
 }
 
-func (t transaction_rpc) CreateBilling(ctx context.Context, correlationId string, trx Transaction) (tx Transaction) {
+func (t transaction_rpc) CreateBilling(ctx context.Context, correlationId string, trx Transaction) (tx Transaction, err error) {
 	requestPayload, _ := json.Marshal(trx)
-	ch := newChannel(t.conn, TOPIC_TRANSACTION)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_TRANSACTION, CREATE_BILLING_QUEUE, CREATE_BILLING_RSP_QUEUE, correlationId, requestPayload)
-	if response != nil {
-		json.Unmarshal(response, &tx)
+	response, err := waitForResponse(ctx, t.t, TOPIC_TRANSACTION, CREATE_BILLING_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return tx, err
 	}
-	return tx
+	err = decodeReply(CREATE_BILLING_QUEUE, response, &tx)
+	return tx, err
 }
 
-func (t transaction_rpc) ListTransactionItem(ctx context.Context, correlationId, transactionUuid string) (carts []TransactionCart) {
-	ch := newChannel(t.conn, TOPIC_TRANSACTION)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_TRANSACTION, LIST_TRANSACTION_ITEM_QUEUE, LIST_TRANSACTION_ITEM_RSP_QUEUE, correlationId, []byte(transactionUuid))
-	if response != nil {
-		json.Unmarshal(response, &carts)
+func (t transaction_rpc) ListTransactionItem(ctx context.Context, correlationId, transactionUuid string) (carts []TransactionCart, err error) {
+	response, err := waitForResponse(ctx, t.t, TOPIC_TRANSACTION, LIST_TRANSACTION_ITEM_QUEUE, correlationId, []byte(transactionUuid))
+	if err != nil {
+		return carts, err
 	}
-	return carts
+	err = decodeReply(LIST_TRANSACTION_ITEM_QUEUE, response, &carts)
+	return carts, err
 }
 
-func (t transaction_rpc) DetailBilling(ctx context.Context, correlationId string, trx Transaction) (tx Transaction) {
+func (t transaction_rpc) DetailBilling(ctx context.Context, correlationId string, trx Transaction) (tx Transaction, err error) {
 	requestPayload, _ := json.Marshal(trx)
-	ch := newChannel(t.conn, TOPIC_TRANSACTION)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_TRANSACTION, DETAIL_BILLING_QUEUE, DETAIL_BILLING_RSP_QUEUE, correlationId, requestPayload)
-	if response != nil {
-		json.Unmarshal(response, &tx)
+	response, err := waitForResponse(ctx, t.t, TOPIC_TRANSACTION, DETAIL_BILLING_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return tx, err
 	}
-	return tx
+	err = decodeReply(DETAIL_BILLING_QUEUE, response, &tx)
+	return tx, err
 }
 
-func (t transaction_rpc) ProcessPayment(ctx context.Context, correlationId string, pF PaymentFlag) (pFResponse PaymentFlag) {
+func (t transaction_rpc) ProcessPayment(ctx context.Context, correlationId string, pF PaymentFlag) (pFResponse PaymentFlag, err error) {
 	requestPayload, _ := json.Marshal(pF)
-	ch := newChannel(t.conn, TOPIC_TRANSACTION)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_TRANSACTION, PROCESS_PAYMENT_QUEUE, PROCESS_PAYMENT_RSP_QUEUE, correlationId, requestPayload)
-	if response != nil {
-		json.Unmarshal(response, &pFResponse)
+	response, err := waitForResponse(ctx, t.t, TOPIC_TRANSACTION, PROCESS_PAYMENT_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return pFResponse, err
 	}
-	return pFResponse
+	err = decodeReply(PROCESS_PAYMENT_QUEUE, response, &pFResponse)
+	return pFResponse, err
+}
+
+func (t transaction_rpc) ApplyVoucher(ctx context.Context, correlationId string, trx Transaction) (tx Transaction, err error) {
+	requestPayload, _ := json.Marshal(trx)
+	response, err := waitForResponse(ctx, t.t, TOPIC_TRANSACTION, APPLY_VOUCHER_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return tx, err
+	}
+	err = decodeReply(APPLY_VOUCHER_QUEUE, response, &tx)
+	return tx, err
+}
+
+func (t transaction_rpc) CancelBilling(ctx context.Context, correlationId string, trx Transaction) (tx Transaction, err error) {
+	requestPayload, _ := json.Marshal(trx)
+	response, err := waitForResponse(ctx, t.t, TOPIC_TRANSACTION, CANCEL_BILLING_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return tx, err
+	}
+	err = decodeReply(CANCEL_BILLING_QUEUE, response, &tx)
+	return tx, err
+}
+
+func (t transaction_rpc) Refund(ctx context.Context, correlationId string, refund TransactionRefund) (r TransactionRefund, err error) {
+	requestPayload, _ := json.Marshal(refund)
+	response, err := waitForResponse(ctx, t.t, TOPIC_TRANSACTION, REFUND_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return r, err
+	}
+	err = decodeReply(REFUND_QUEUE, response, &r)
+	return r, err
 }
@@ -72,9 +72,12 @@ func (u User) loginMutation() *graphql.Field {
 			email := p.Args["email"].(string)
 			password := p.Args["password"].(string)
 			correlationId := u.ctx.Locals("uuid").(string)
-			status, result := u.r.User().GetByEmail(p.Context, correlationId, email)
-			if !status {
-				return nil, errors.New("user not found")
+			result, err := u.r.User().GetByEmail(p.Context, correlationId, email)
+			if IsNotFound(err) {
+				return nil, newGraphqlError(CODE_NOT_FOUND, "user not found")
+			}
+			if err != nil {
+				return nil, rpcError(err)
 			}
 			isPasswordMatch, _ := helpers.MatchHash(password, result.Password)
 			if !isPasswordMatch {
@@ -82,11 +85,11 @@ func (u User) loginMutation() *graphql.Field {
 			}
 			session := helpers.RandomString(64, correlationId)
 			result.Session = session
-			setSession := u.r.User().SaveSession(p.Context, correlationId, result)
-			if setSession {
-				return result, nil
+			err = u.r.User().SaveSession(p.Context, correlationId, result)
+			if err != nil {
+				return nil, rpcError(err)
 			}
-			return nil, errors.New("Internal server error")
+			return result, nil
 		},
 	}
 }
@@ -114,11 +117,14 @@ func (u User) registerMutation() *graphql.Field {
 				Email:    p.Args["email"].(string),
 				Password: password,
 			}
-			status, result := u.r.User().Register(p.Context, correlationId, user)
-			if status {
-				return result, nil
+			result, err := u.r.User().Register(p.Context, correlationId, user)
+			if IsDuplicate(err) {
+				return nil, newGraphqlError(CODE_DUPLICATE, "User already registered")
+			}
+			if err != nil {
+				return nil, rpcError(err)
 			}
-			return nil, errors.New("User already registered")
+			return result, nil
 		},
 	}
 }
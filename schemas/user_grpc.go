@@ -0,0 +1,47 @@
+package schemas
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"wahyuade.com/simple-e-commerce/pb"
+)
+
+type user_grpc struct {
+	client pb.UserServiceClient
+}
+
+func newUserGrpc(conn *grpc.ClientConn) user_grpc {
+	return user_grpc{
+		client: pb.NewUserServiceClient(conn),
+	}
+}
+
+func (u user_grpc) GetByEmail(ctx context.Context, correlationId, email string) (User, error) {
+	resp, err := u.client.GetByEmail(withCorrelationId(ctx, correlationId), &pb.GetByEmailRequest{Email: email})
+	if err != nil {
+		return User{}, grpcError(GET_BY_EMAIL_QUEUE, correlationId, err)
+	}
+	return UserFromPB(resp), nil
+}
+
+func (u user_grpc) Register(ctx context.Context, correlationId string, user User) (User, error) {
+	resp, err := u.client.Register(withCorrelationId(ctx, correlationId), UserToPB(user))
+	if err != nil {
+		return User{}, grpcError(REGISTER_USER_QUEUE, correlationId, err)
+	}
+	return UserFromPB(resp), nil
+}
+
+func (u user_grpc) SaveSession(ctx context.Context, correlationId string, user User) error {
+	_, err := u.client.SaveSession(withCorrelationId(ctx, correlationId), UserToPB(user))
+	return grpcError(SET_USER_SESSION_QUEUE, correlationId, err)
+}
+
+func (u user_grpc) GetBySession(ctx context.Context, correlationId, session string) (User, error) {
+	resp, err := u.client.GetBySession(withCorrelationId(ctx, correlationId), &pb.GetBySessionRequest{Session: session})
+	if err != nil {
+		return User{}, grpcError(GET_USER_BY_SESSION_QUEUE, correlationId, err)
+	}
+	return UserFromPB(resp), nil
+}
@@ -4,71 +4,61 @@ import (
 	"context"
 	"encoding/json"
 
-	"github.com/rabbitmq/amqp091-go"
+	"wahyuade.com/simple-e-commerce/broker"
 )
 
 const TOPIC_USER = "user-service"
 
 const GET_BY_EMAIL_QUEUE = "getByEmail"
-const GET_BY_EMAIL_RSP_QUEUE = "getByEmailRsp"
 const REGISTER_USER_QUEUE = "registerUser"
-const REGISTER_USER_RSP_QUEUE = "registerUserRsp"
 const SET_USER_SESSION_QUEUE = "setUserSession"
-const SET_USER_SESSION_RSP_QUEUE = "setUserSessionRsp"
 const GET_USER_BY_SESSION_QUEUE = "getUserBySession"
-const GET_USER_BY_SESSION_RSP_QUEUE = "getUserBySessionRsp"
 
 type UserRPC interface {
-	GetByEmail(ctx context.Context, correlationId, email string) (bool, User)
-	Register(ctx context.Context, correlationId string, user User) (bool, User)
-	SaveSession(ctx context.Context, correlationId string, user User) bool
-	GetBySession(ctx context.Context, correlationId, session string) User
+	GetByEmail(ctx context.Context, correlationId, email string) (User, error)
+	Register(ctx context.Context, correlationId string, user User) (User, error)
+	SaveSession(ctx context.Context, correlationId string, user User) error
+	GetBySession(ctx context.Context, correlationId, session string) (User, error)
 }
 
 type user_rpc struct {
-	conn *amqp091.Connection
+	t broker.Transport
 }
 
-func (u user_rpc) GetByEmail(ctx context.Context, correlationId, email string) (bool, User) {
-	user := User{
-		Email: email,
+func (u user_rpc) GetByEmail(ctx context.Context, correlationId, email string) (user User, err error) {
+	requestPayload, _ := json.Marshal(User{Email: email})
+	response, err := waitForResponse(ctx, u.t, TOPIC_USER, GET_BY_EMAIL_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return user, err
 	}
-	requestPayload, _ := json.Marshal(user)
-	ch := newChannel(u.conn, TOPIC_USER)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_USER, GET_BY_EMAIL_QUEUE, GET_BY_EMAIL_RSP_QUEUE, correlationId, requestPayload)
-	if response != nil {
-		json.Unmarshal(response, &user)
-		return user.Uuid != "", user
-	}
-	return false, user
+	err = decodeReply(GET_BY_EMAIL_QUEUE, response, &user)
+	return user, err
 }
 
-func (u user_rpc) Register(ctx context.Context, correlationId string, user User) (bool, User) {
+func (u user_rpc) Register(ctx context.Context, correlationId string, user User) (registered User, err error) {
 	requestPayload, _ := json.Marshal(user)
-	ch := newChannel(u.conn, TOPIC_USER)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_USER, REGISTER_USER_QUEUE, REGISTER_USER_RSP_QUEUE, correlationId, requestPayload)
-	if response != nil {
-		json.Unmarshal(response, &user)
-		return user.Uuid != "", user
+	response, err := waitForResponse(ctx, u.t, TOPIC_USER, REGISTER_USER_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return registered, err
 	}
-	return false, user
+	err = decodeReply(REGISTER_USER_QUEUE, response, &registered)
+	return registered, err
 }
 
-func (u user_rpc) SaveSession(ctx context.Context, correlationId string, user User) bool {
+func (u user_rpc) SaveSession(ctx context.Context, correlationId string, user User) error {
 	requestPayload, _ := json.Marshal(user)
-	ch := newChannel(u.conn, TOPIC_USER)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_USER, SET_USER_SESSION_QUEUE, SET_USER_SESSION_RSP_QUEUE, correlationId, requestPayload)
-	return response != nil && string(response) == "true"
+	response, err := waitForResponse(ctx, u.t, TOPIC_USER, SET_USER_SESSION_QUEUE, correlationId, requestPayload)
+	if err != nil {
+		return err
+	}
+	return decodeReply(SET_USER_SESSION_QUEUE, response, nil)
 }
 
-func (u user_rpc) GetBySession(ctx context.Context, correlationId string, session string) User {
-	ch := newChannel(u.conn, TOPIC_USER)
-	defer ch.Close()
-	response := waitForResponse(ctx, ch, TOPIC_USER, GET_USER_BY_SESSION_QUEUE, GET_USER_BY_SESSION_RSP_QUEUE, correlationId, []byte(session))
-	user := User{}
-	json.Unmarshal(response, &user)
-	return user
+func (u user_rpc) GetBySession(ctx context.Context, correlationId string, session string) (user User, err error) {
+	response, err := waitForResponse(ctx, u.t, TOPIC_USER, GET_USER_BY_SESSION_QUEUE, correlationId, []byte(session))
+	if err != nil {
+		return user, err
+	}
+	err = decodeReply(GET_USER_BY_SESSION_QUEUE, response, &user)
+	return user, err
 }
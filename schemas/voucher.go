@@ -0,0 +1,53 @@
+package schemas
+
+import (
+	"errors"
+	"time"
+)
+
+type Voucher struct {
+	Code         string    `json:"code,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	Type         string    `json:"type,omitempty"`
+	Value        int       `json:"value,omitempty"`
+	MaxDiscount  int       `json:"max_discount,omitempty"`
+	MinSpend     int       `json:"min_spend,omitempty"`
+	UsageLimit   int       `json:"usage_limit,omitempty"`
+	ValidFrom    time.Time `json:"valid_from,omitempty"`
+	ValidUntil   time.Time `json:"valid_until,omitempty"`
+	ProductUuids []string  `json:"product_uuids,omitempty"`
+}
+
+// type of a voucher, a PERCENT voucher gives Value percent off up to
+// MaxDiscount when it is set, a FIXED voucher gives Value rupiah off
+const VOUCHER_PERCENT = "PERCENT"
+const VOUCHER_FIXED = "FIXED"
+
+// reasons a voucher is rejected, replied as the message of the error
+const VOUCHER_NOT_FOUND = "voucher not found"
+const VOUCHER_NOT_STARTED = "voucher is not valid yet"
+const VOUCHER_ENDED = "voucher is no longer valid"
+const VOUCHER_NOT_APPLICABLE = "voucher does not apply to the cart"
+const VOUCHER_MIN_SPEND = "minimum spend of the voucher is not reached"
+const VOUCHER_LIMIT_REACHED = "usage limit of the voucher is reached"
+
+var voucherErrorMessage = map[string]string{
+	VOUCHER_NOT_FOUND:      "voucher tidak ditemukan",
+	VOUCHER_NOT_STARTED:    "voucher belum berlaku",
+	VOUCHER_ENDED:          "voucher sudah tidak berlaku",
+	VOUCHER_NOT_APPLICABLE: "voucher tidak berlaku untuk produk di keranjang",
+	VOUCHER_MIN_SPEND:      "belanja belum mencapai minimum pemakaian voucher",
+	VOUCHER_LIMIT_REACHED:  "batas pemakaian voucher sudah tercapai",
+}
+
+// voucherError tells the user why the voucher is rejected, other errors are
+// handled like rpcError.
+func voucherError(err error) error {
+	var rErr *RPCError
+	if errors.As(err, &rErr) {
+		if message, ok := voucherErrorMessage[rErr.Message]; ok {
+			return newGraphqlError(rErr.Code, message)
+		}
+	}
+	return rpcError(err)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+// BILLING_EXPIRY_INTERVAL is how often the unpaid bills past their expiry are
+// looked for.
+const BILLING_EXPIRY_INTERVAL = time.Minute
+
+// expireBillings expires the unpaid bills past their expiry until the service
+// stops consuming. The stock reserved for them is released by the product
+// service on its own.
+func (tS TransactionService) expireBillings() {
+	ticker := time.NewTicker(BILLING_EXPIRY_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-tS.consuming.Done():
+			return
+		case <-ticker.C:
+		}
+		for {
+			expired, err := tS.expireBilling(context.Background())
+			if err != nil {
+				log.Printf("Failed to expire the bills: %s", err)
+				break
+			}
+			if expired == nil {
+				break
+			}
+			log.Printf("Bill %s expired", expired.Uuid)
+		}
+	}
+}
+
+// expireBilling expires one unpaid or partially paid bill past its expiry,
+// skipping those being paid, puts its items back in the cart of the user and
+// gives the use of its voucher back. What was paid for it is owed to the user
+// as credit. It returns nil when there is none left.
+func (tS TransactionService) expireBilling(ctx context.Context) (*schemas.Transaction, error) {
+	tx, err := tS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var transaction schemas.Transaction
+	row := tx.QueryRow(
+		`UPDATE "transaction" SET status = $1 WHERE uuid IN (
+			SELECT uuid FROM "transaction" WHERE status IN ($2, $3) AND expired <= $4
+			ORDER BY expired LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING uuid, user_uuid, status, amount, payment_method, created, expired, virtual_account, breakdown, paid`,
+		schemas.STATUS_EXPIRED,
+		schemas.STATUS_UNPAID,
+		schemas.STATUS_PARTIALLY_PAID,
+		time.Now(),
+	)
+	err = row.Scan(
+		&transaction.Uuid,
+		&transaction.UserUuid,
+		&transaction.Status,
+		&transaction.Amount,
+		&transaction.PaymentMethod,
+		&transaction.Created,
+		&transaction.Expired,
+		&transaction.VirtualAccount,
+		breakdownColumn{&transaction.Breakdown},
+		&transaction.Paid,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if transaction.Paid > 0 {
+		err = recordCredit(tx, transaction.UserUuid, transaction.Uuid, "", transaction.Paid, CREDIT_EXPIRED_PAYMENT)
+		if err != nil {
+			return nil, err
+		}
+		transaction.Credit = transaction.Paid
+	}
+
+	if err = returnCartItems(tx, transaction.Uuid); err != nil {
+		return nil, err
+	}
+	if err = releaseVoucher(tx, transaction.Uuid); err != nil {
+		return nil, err
+	}
+	if err = writeEvent(tx, tS.topic, BillingExpired{transaction}); err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// returnCartItems puts the items of the bill back in the cart of the user
+// within tx.
+func returnCartItems(tx *sql.Tx, transactionUuid string) error {
+	// the user may have put the same product in the cart since, the
+	// quantities are merged so there is still one cart row per product
+	_, err := tx.Exec(
+		`UPDATE "transaction_cart" c SET qty = c.qty + e.qty FROM "transaction_cart" e
+		WHERE e.transaction_uuid = $1 AND c.transaction_uuid IS NULL
+		AND c.user_uuid = e.user_uuid AND c.product_uuid = e.product_uuid`,
+		transactionUuid,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`DELETE FROM "transaction_cart" e WHERE e.transaction_uuid = $1 AND EXISTS (
+			SELECT 1 FROM "transaction_cart" c WHERE c.transaction_uuid IS NULL
+			AND c.user_uuid = e.user_uuid AND c.product_uuid = e.product_uuid
+		)`,
+		transactionUuid,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE "transaction_cart" SET transaction_uuid = NULL WHERE transaction_uuid = $1`, transactionUuid)
+	return err
+}
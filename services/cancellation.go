@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+// cancelBilling cancels the unpaid bill of the user, its items are put back
+// in the cart and the use of its voucher is given back. The product service
+// releases the stock reserved for it on BillingCancelled.
+func (tS TransactionService) cancelBilling(ctx context.Context, transaction schemas.Transaction) (schemas.Transaction, error) {
+	tx, err := tS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return transaction, dbError(err)
+	}
+	cancelled := transaction
+	row := tx.QueryRow(
+		`UPDATE "transaction" SET status = $1
+		WHERE payment_method = $2 AND virtual_account = $3 AND user_uuid = $4 AND status = $5
+		RETURNING uuid, user_uuid, status, amount, payment_method, created, expired, virtual_account, breakdown`,
+		schemas.STATUS_CANCELLED,
+		transaction.PaymentMethod,
+		transaction.VirtualAccount,
+		transaction.UserUuid,
+		schemas.STATUS_UNPAID,
+	)
+	err = row.Scan(
+		&cancelled.Uuid,
+		&cancelled.UserUuid,
+		&cancelled.Status,
+		&cancelled.Amount,
+		&cancelled.PaymentMethod,
+		&cancelled.Created,
+		&cancelled.Expired,
+		&cancelled.VirtualAccount,
+		breakdownColumn{&cancelled.Breakdown},
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		// tells a bill that does not exist from one that is not unpaid
+		if _, err = tS.detailBilling(ctx, transaction); err != nil {
+			return transaction, err
+		}
+		return transaction, newHandlerError(schemas.CODE_INVALID_REQUEST, "transaction is not waiting for payment")
+	}
+	if err != nil {
+		tx.Rollback()
+		return transaction, dbError(err)
+	}
+	if err = returnCartItems(tx, cancelled.Uuid); err != nil {
+		tx.Rollback()
+		return transaction, dbError(err)
+	}
+	if err = releaseVoucher(tx, cancelled.Uuid); err != nil {
+		tx.Rollback()
+		return transaction, dbError(err)
+	}
+	err = writeEvent(tx, tS.topic, BillingCancelled{cancelled})
+	if err != nil {
+		tx.Rollback()
+		return transaction, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return transaction, dbError(err)
+	}
+	return cancelled, nil
+}
+
+// refund gives back refund.Amount of the payment of a paid bill, the whole
+// amount left when it is not set. The overpayment is not part of it, it is
+// already owed to the user as credit. The bill is REFUNDED once nothing is left.
+//
+// The stock of the items is restored by the product service on
+// BillingRefunded. Refunding what is left without items restores the items
+// not restored yet.
+func (tS TransactionService) refund(ctx context.Context, refund schemas.TransactionRefund) (schemas.TransactionRefund, error) {
+	return tS.refundBilling(ctx, refund, true)
+}
+
+// refundBilling is refund, the items not restored yet are only added to a
+// refund of what is left without items when restock is set.
+func (tS TransactionService) refundBilling(ctx context.Context, refund schemas.TransactionRefund, restock bool) (schemas.TransactionRefund, error) {
+	if refund.Amount < 0 {
+		return refund, newHandlerError(schemas.CODE_INVALID_REQUEST, "refund amount can not be negative")
+	}
+	tx, err := tS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return refund, dbError(err)
+	}
+	// locked so the refunds of the bill are made one at a time
+	var status string
+	row := tx.QueryRow(
+		`SELECT status, COALESCE(order_uuid::text, '') FROM "transaction" WHERE uuid = $1 FOR UPDATE`,
+		refund.TransactionUuid,
+	)
+	if err = row.Scan(&status, &refund.OrderUuid); err != nil {
+		tx.Rollback()
+		return refund, dbError(err)
+	}
+	if err = refundable(status); err != nil {
+		tx.Rollback()
+		return refund, err
+	}
+	var left int
+	row = tx.QueryRow(
+		`SELECT
+			(SELECT COALESCE(SUM(amount), 0) FROM "transaction_payment" WHERE transaction_uuid = $1) -
+			(SELECT COALESCE(SUM(amount), 0) FROM "customer_credit" WHERE transaction_uuid = $1) -
+			(SELECT COALESCE(SUM(amount), 0) FROM "transaction_refund" WHERE transaction_uuid = $1)`,
+		refund.TransactionUuid,
+	)
+	if err = row.Scan(&left); err != nil {
+		tx.Rollback()
+		return refund, dbError(err)
+	}
+	if refund.Amount == 0 {
+		refund.Amount = left
+	}
+	if refund.Amount == 0 || refund.Amount > left {
+		tx.Rollback()
+		return refund, newHandlerError(schemas.CODE_INVALID_REQUEST, fmt.Sprintf("refund amount has to be between 1 and %d", left))
+	}
+	refund.Items, err = refundItems(tx, refund, restock && refund.Amount == left)
+	if err != nil {
+		tx.Rollback()
+		return refund, err
+	}
+
+	refund.Uuid = uuid.NewString()
+	refund.Created = time.Now()
+	_, err = tx.Exec(
+		`INSERT INTO "transaction_refund" (uuid, transaction_uuid, amount, reason, created) VALUES ($1, $2, $3, $4, $5)`,
+		refund.Uuid,
+		refund.TransactionUuid,
+		refund.Amount,
+		refund.Reason,
+		refund.Created,
+	)
+	if err != nil {
+		tx.Rollback()
+		return refund, dbError(err)
+	}
+	for _, i := range refund.Items {
+		_, err = tx.Exec(
+			`INSERT INTO "transaction_refund_item" (refund_uuid, product_uuid, qty) VALUES ($1, $2, $3)`,
+			refund.Uuid,
+			i.ProductUuid,
+			i.Qty,
+		)
+		if err != nil {
+			tx.Rollback()
+			return refund, dbError(err)
+		}
+	}
+	refund.TransactionStatus = schemas.STATUS_PAID
+	if refund.Amount == left {
+		refund.TransactionStatus = schemas.STATUS_REFUNDED
+		_, err = tx.Exec(`UPDATE "transaction" SET status = $1 WHERE uuid = $2`, schemas.STATUS_REFUNDED, refund.TransactionUuid)
+		if err != nil {
+			tx.Rollback()
+			return refund, dbError(err)
+		}
+	}
+	err = writeEvent(tx, tS.topic, BillingRefunded{refund})
+	if err != nil {
+		tx.Rollback()
+		return refund, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return refund, dbError(err)
+	}
+	return refund, nil
+}
+
+// refundable tells why a bill with status can not be refunded. A partially
+// paid bill has no order and its stock is only reserved, its payments are
+// credited to the user once it expires instead.
+func refundable(status string) error {
+	switch status {
+	case schemas.STATUS_PAID:
+		return nil
+	case schemas.STATUS_PARTIALLY_PAID:
+		return newHandlerError(schemas.CODE_INVALID_REQUEST, "transaction is partially paid, its payments are credited to the user once it expires")
+	}
+	return newHandlerError(schemas.CODE_INVALID_REQUEST, "transaction is not paid")
+}
+
+// refundItems checks the items of the refund were bought and not restored
+// yet. When none are given and all is set, every item not restored yet is
+// returned.
+func refundItems(tx *sql.Tx, refund schemas.TransactionRefund, all bool) ([]schemas.TransactionRefundItem, error) {
+	rows, err := tx.Query(
+		`SELECT c.product_uuid, c.qty - COALESCE((
+			SELECT SUM(i.qty) FROM "transaction_refund_item" i
+			JOIN "transaction_refund" r ON r.uuid = i.refund_uuid
+			WHERE r.transaction_uuid = c.transaction_uuid AND i.product_uuid = c.product_uuid
+		), 0) FROM "transaction_cart" c WHERE c.transaction_uuid = $1`,
+		refund.TransactionUuid,
+	)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	notRestored := map[string]int{}
+	var remaining []schemas.TransactionRefundItem
+	for rows.Next() {
+		var i schemas.TransactionRefundItem
+		rows.Scan(&i.ProductUuid, &i.Qty)
+		notRestored[i.ProductUuid] = i.Qty
+		if i.Qty > 0 {
+			remaining = append(remaining, i)
+		}
+	}
+	rows.Close()
+	if len(refund.Items) == 0 {
+		if all {
+			return remaining, nil
+		}
+		return nil, nil
+	}
+	// a product listed twice is merged into one item
+	var items []schemas.TransactionRefundItem
+	index := map[string]int{}
+	for _, i := range refund.Items {
+		if i.Qty <= 0 || i.Qty > notRestored[i.ProductUuid] {
+			return nil, newHandlerError(schemas.CODE_INVALID_REQUEST, fmt.Sprintf("at most %d of %s can be refunded", notRestored[i.ProductUuid], i.ProductUuid))
+		}
+		notRestored[i.ProductUuid] -= i.Qty
+		if n, ok := index[i.ProductUuid]; ok {
+			items[n].Qty += i.Qty
+			continue
+		}
+		index[i.ProductUuid] = len(items)
+		items = append(items, i)
+	}
+	return items, nil
+}
+
+// refundCancelledOrder refunds what is left of the payment of the bill of the
+// cancelled order. The refund has no items, the order-service restores the
+// stock of the order on its own.
+func (tS TransactionService) refundCancelledOrder(ctx context.Context, e OrderStatusChanged) error {
+	if e.Status != schemas.ORDER_STATUS_CANCELLED {
+		return nil
+	}
+	var transactionUuid, status string
+	row := tS.dbConn.QueryRowContext(ctx, `SELECT uuid, status FROM "transaction" WHERE order_uuid = $1`, e.Uuid)
+	err := row.Scan(&transactionUuid, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// refunded by an earlier delivery of the event
+	if status != schemas.STATUS_PAID {
+		return nil
+	}
+	reason := "order cancelled"
+	if e.Note != "" {
+		reason += ": " + e.Note
+	}
+	_, err = tS.refundBilling(ctx, schemas.TransactionRefund{
+		TransactionUuid: transactionUuid,
+		Reason:          reason,
+	}, false)
+	return err
+}
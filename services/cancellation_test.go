@@ -0,0 +1,27 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+func TestRefundable(t *testing.T) {
+	require.NoError(t, refundable(schemas.STATUS_PAID))
+
+	for _, status := range []string{
+		schemas.STATUS_PARTIALLY_PAID,
+		schemas.STATUS_UNPAID,
+		schemas.STATUS_EXPIRED,
+		schemas.STATUS_CANCELLED,
+		schemas.STATUS_REFUNDED,
+	} {
+		err := refundable(status)
+		require.Error(t, err, status)
+		code, _ := errorCode(err)
+		require.Equal(t, schemas.CODE_INVALID_REQUEST, code, status)
+	}
+	_, message := errorCode(refundable(schemas.STATUS_PARTIALLY_PAID))
+	require.Contains(t, message, "partially paid")
+}
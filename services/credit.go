@@ -0,0 +1,29 @@
+package services
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// reason of a customer credit
+const CREDIT_OVERPAYMENT = "OVERPAYMENT"
+const CREDIT_EXPIRED_PAYMENT = "EXPIRED_PAYMENT"
+
+// recordCredit records within tx amount owed to the user of the bill, either
+// paid over the amount of the bill by the payment with reference or paid for
+// a bill which expired before it was fully paid, without reference.
+func recordCredit(tx *sql.Tx, userUuid, transactionUuid, reference string, amount int, reason string) error {
+	_, err := tx.Exec(
+		`INSERT INTO "customer_credit" (uuid, user_uuid, transaction_uuid, reference, amount, reason, created) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		uuid.NewString(),
+		userUuid,
+		transactionUuid,
+		sql.NullString{String: reference, Valid: reference != ""},
+		amount,
+		reason,
+		time.Now(),
+	)
+	return err
+}
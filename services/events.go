@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"wahyuade.com/simple-e-commerce/broker"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+// DomainEvent is published to TOPIC_DOMAIN_EVENTS with EventName as routing
+// key. The events embed the entity they are about, which is their payload.
+type DomainEvent interface {
+	EventName() string
+}
+
+type ProductCreated struct{ schemas.Product }
+
+func (ProductCreated) EventName() string { return "product.created" }
+
+// StockDepleted is published when a product runs out of stock.
+type StockDepleted struct{ schemas.Product }
+
+func (StockDepleted) EventName() string { return "stock.depleted" }
+
+// CartUpdated is published when a product is added to a cart or its
+// quantity changes.
+type CartUpdated struct{ schemas.TransactionCart }
+
+func (CartUpdated) EventName() string { return "cart.updated" }
+
+type BillingCreated struct{ schemas.Transaction }
+
+func (BillingCreated) EventName() string { return "billing.created" }
+
+// BillingExpired is published when a bill is left unpaid past its expiry,
+// its cart items are back in the cart of the user.
+type BillingExpired struct{ schemas.Transaction }
+
+func (BillingExpired) EventName() string { return "billing.expired" }
+
+// BillingCancelled is published when the user cancels an unpaid bill, its
+// cart items are back in the cart of the user.
+type BillingCancelled struct{ schemas.Transaction }
+
+func (BillingCancelled) EventName() string { return "billing.cancelled" }
+
+// BillingRefunded is published for every refund of a paid bill, the stock of
+// its items is to be restored.
+type BillingRefunded struct{ schemas.TransactionRefund }
+
+func (BillingRefunded) EventName() string { return "billing.refunded" }
+
+type PaymentReceived struct{ schemas.PaymentFlag }
+
+func (PaymentReceived) EventName() string { return "payment.received" }
+
+// PaymentReverted is published when the payment saga is compensated after
+// the payment was received.
+type PaymentReverted struct{ schemas.PaymentFlag }
+
+func (PaymentReverted) EventName() string { return "payment.reverted" }
+
+type OrderCreated struct{ schemas.Order }
+
+func (OrderCreated) EventName() string { return "order.created" }
+
+type OrderDeleted struct{ schemas.Order }
+
+func (OrderDeleted) EventName() string { return "order.deleted" }
+
+// OrderStatusChanged is published when the order moves to another status,
+// From is the status it left. A CANCELLED order gets its stock back and the
+// rest of the payment of its bill refunded.
+type OrderStatusChanged struct {
+	schemas.Order
+	From string `json:"from"`
+	Note string `json:"note,omitempty"`
+}
+
+func (OrderStatusChanged) EventName() string { return "order.status_changed" }
+
+type eventKey struct{}
+
+// EventOf returns the envelope of the event being handled by a subscriber,
+// its Id is the same on every delivery of the event.
+func EventOf(ctx context.Context) Event {
+	e, _ := ctx.Value(eventKey{}).(Event)
+	return e
+}
+
+// Subscribe calls fn with every E published to TOPIC_DOMAIN_EVENTS, and
+// blocks until the service stops consuming or the connection drops, like
+// Handle. It is meant to be started from RegisterAllSubscriber.
+//
+// Each service has its own durable queue per event, so every subscribed
+// service receives the event once however many workers it runs. An event
+// may be delivered more than once, fn failing is retried following
+// DefaultRetryPolicy and the event is dead lettered after the last attempt,
+// see the dead-letter command.
+func Subscribe[E DomainEvent](rt Runtime, fn func(context.Context, E) error) {
+	var zero E
+	name := zero.EventName()
+	queue := subscriptionQueue(rt.topic, name)
+	log.Printf("%s Subscriber Registered\n", queue)
+	consume(rt, TOPIC_DOMAIN_EVENTS, queue, name, func(d broker.Delivery) {
+		var e Event
+		var payload E
+		err := json.Unmarshal(d.Body, &e)
+		if err == nil {
+			err = json.Unmarshal(e.Payload, &payload)
+		}
+		if err != nil {
+			log.Printf("Dropping invalid %s event: %s", name, err)
+			d.Nack(false)
+			return
+		}
+		ctx := context.WithValue(rt.ctx, eventKey{}, e)
+		ctx = context.WithValue(ctx, correlationIdKey{}, e.Id)
+		handleEvent(ctx, d, payload, fn)
+	})
+	log.Printf("%s Subscriber Stopped\n", queue)
+}
+
+func subscriptionQueue(subscriber, event string) string {
+	return fmt.Sprintf("%s.%s", subscriber, event)
+}
+
+// handleEvent calls fn until it succeeds or runs out of the attempts of
+// DefaultRetryPolicy. The event is retried in place rather than through
+// the retry queue since its routing key is shared by every subscriber.
+func handleEvent[E DomainEvent](ctx context.Context, d broker.Delivery, payload E, fn func(context.Context, E) error) {
+	var err error
+	for attempt := 0; attempt < DefaultRetryPolicy.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := DefaultRetryPolicy.backoff(attempt - 1)
+			log.Printf("Retrying %s event %s in %s: %s", d.RoutingKey, EventOf(ctx).Id, delay, err)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				d.Nack(true)
+				return
+			}
+		}
+		if err = fn(ctx, payload); err == nil {
+			d.Ack()
+			return
+		}
+	}
+	log.Printf("Dead lettering %s event %s after %d attempts: %s", d.RoutingKey, EventOf(ctx).Id, DefaultRetryPolicy.MaxAttempts, err)
+	if nErr := d.Nack(false); nErr != nil {
+		log.Printf("Failed to dead letter %s event %s: %s", d.RoutingKey, EventOf(ctx).Id, nErr)
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"wahyuade.com/simple-e-commerce/database"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+// GrpcRunner is implemented by the services that can be served over grpc.
+type GrpcRunner interface {
+	RegisterGrpc(*grpc.Server)
+}
+
+type grpcService struct {
+	addr string
+	// rt subscribes to the domain events and relays the outbox through
+	// RabbitMQ, only the calls between the services go through grpc
+	rt     Runtime
+	server *grpc.Server
+	stop   context.CancelFunc
+	abort  context.CancelFunc
+}
+
+// NewGrpc serves the service on addr over grpc instead of RabbitMQ.
+func NewGrpc(addr string) Service {
+	rt, stop, abort := newRuntime(DialTransport(), schemas.InitGrpc(), database.InitDB(), 1)
+	return grpcService{
+		addr:   addr,
+		rt:     rt,
+		server: grpc.NewServer(grpc.UnaryInterceptor(logCorrelationId)),
+		stop:   stop,
+		abort:  abort,
+	}
+}
+
+func (s grpcService) Bootstrap(runner ServiceRunner) {
+	log.Printf("Bootstraping the %s over grpc...\n", runner.Name())
+	svc := runner.Init(s.rt)
+	grpcSvc, ok := svc.(GrpcRunner)
+	if !ok {
+		log.Panicf("%s can not be served over grpc", runner.Name())
+	}
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		log.Panicf("Failed to listen on %s: %s", s.addr, err)
+	}
+	grpcSvc.RegisterGrpc(s.server)
+	svc.Start()
+	reregister(s.rt, svc, false)
+	go func() {
+		log.Printf("%s is listening on %s\n", runner.Name(), s.addr)
+		if err := s.server.Serve(lis); err != nil {
+			log.Printf("%s stopped: %s", runner.Name(), err)
+		}
+	}()
+}
+
+// Shutdown stops accepting calls and consuming the events, then waits for
+// the ongoing ones until ctx is done, after which they are cancelled.
+func (s grpcService) Shutdown(ctx context.Context) error {
+	s.stop()
+	finished := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		s.rt.consumers.Wait()
+		close(finished)
+	}()
+	var err error
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		err = fmt.Errorf("calls still being handled after the shutdown timeout: %w", ctx.Err())
+		s.server.Stop()
+		s.abort()
+		select {
+		case <-finished:
+		case <-time.After(SHUTDOWN_ROLLBACK_DELAY):
+		}
+	}
+	if cErr := s.rt.transport.Close(); cErr != nil && err == nil {
+		err = cErr
+	}
+	if cErr := s.rt.rpc.Close(); cErr != nil && err == nil {
+		err = cErr
+	}
+	if cErr := s.rt.dbConn.Close(); cErr != nil && err == nil {
+		err = cErr
+	}
+	return err
+}
+
+// logCorrelationId passes the correlation id sent by the gateway to the
+// handlers, the same way Handle does, and logs the failed calls along with it.
+func logCorrelationId(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var correlationId string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(schemas.CORRELATION_ID_METADATA); len(v) > 0 {
+			correlationId = v[0]
+		}
+	}
+	log.Printf("Received request for %s with correlation-id: %s", info.FullMethod, correlationId)
+	resp, err := handler(context.WithValue(ctx, correlationIdKey{}, correlationId), req)
+	if err != nil {
+		log.Printf("%s failed with correlation-id %s: %s", info.FullMethod, correlationId, err)
+	}
+	return resp, err
+}
+
+// grpcError turns a handler error into a grpc status.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	code, message := errorCode(err)
+	return status.Error(schemas.GrpcCode(code), message)
+}
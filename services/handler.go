@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"wahyuade.com/simple-e-commerce/broker"
+)
+
+type correlationIdKey struct{}
+
+// CorrelationId returns the correlation id of the request being handled.
+func CorrelationId(ctx context.Context) string {
+	correlationId, _ := ctx.Value(correlationIdKey{}).(string)
+	return correlationId
+}
+
+// Handle serves the requests sent to queue on the topic of rt with fn, and
+// blocks until the service stops consuming or the connection drops.
+//
+// The body is decoded as JSON into Req, a string or []byte Req receives the
+// raw body. The result of fn is sent back in the reply envelope, transient
+// failures are retried and requests that can not be decoded are dead
+// lettered.
+func Handle[Req, Resp any](rt Runtime, queue string, fn func(context.Context, Req) (Resp, error)) {
+	log.Printf("%s Handler Registered\n", queue)
+	consume(rt, rt.topic, queue, queue, func(d broker.Delivery) {
+		log.Printf("Received request for %s with correlation-id: %s", queue, d.CorrelationId)
+		req, err := decode[Req](d.Body)
+		if err != nil {
+			reject(rt.transport, d, err)
+			return
+		}
+		ctx := context.WithValue(rt.ctx, correlationIdKey{}, d.CorrelationId)
+		respond(rt.transport, d, replyOf(fn(ctx, req)))
+	})
+	log.Printf("%s Handler Stopped\n", queue)
+}
+
+func decode[Req any](body []byte) (Req, error) {
+	var req Req
+	switch r := any(&req).(type) {
+	case *string:
+		*r = string(body)
+	case *[]byte:
+		*r = body
+	default:
+		if len(body) == 0 {
+			return req, nil
+		}
+		err := json.Unmarshal(body, &req)
+		return req, err
+	}
+	return req, nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"wahyuade.com/simple-e-commerce/pb"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+type orderGrpc struct {
+	pb.UnimplementedOrderServiceServer
+	oS OrderService
+}
+
+func (oS OrderService) RegisterGrpc(server *grpc.Server) {
+	pb.RegisterOrderServiceServer(server, orderGrpc{oS: oS})
+}
+
+func (o orderGrpc) ListOrder(ctx context.Context, req *pb.UserRequest) (*pb.OrderList, error) {
+	orders, err := o.oS.listOrder(ctx, req.GetUserUuid())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.OrderListToPB(orders), nil
+}
+
+func (o orderGrpc) DetailOrder(ctx context.Context, req *pb.Order) (*pb.Order, error) {
+	order, err := o.oS.detailOrder(ctx, schemas.OrderFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.OrderToPB(order), nil
+}
+
+func (o orderGrpc) ProcessOrder(ctx context.Context, req *pb.Order) (*pb.Order, error) {
+	order, err := o.oS.processOrder(ctx, schemas.OrderFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.OrderToPB(order), nil
+}
+
+func (o orderGrpc) DeleteOrder(ctx context.Context, req *pb.Order) (*pb.Empty, error) {
+	_, err := o.oS.deleteOrder(ctx, schemas.OrderFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (o orderGrpc) UpdateOrderStatus(ctx context.Context, req *pb.OrderTransition) (*pb.Order, error) {
+	order, err := o.oS.updateOrderStatus(ctx, schemas.OrderTransitionFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.OrderToPB(order), nil
+}
@@ -1,14 +1,13 @@
 package services
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/google/uuid"
-	"github.com/rabbitmq/amqp091-go"
 	"wahyuade.com/simple-e-commerce/schemas"
 )
 
@@ -17,182 +16,253 @@ const TOPIC_ORDER = "order-service"
 const LIST_ORDER_QUEUE = "listOrderQueue"
 const DETAIL_ORDER_QUEUE = "detailOrderQueue"
 const PROCESS_ORDER_QUEUE = "processOrderQueue"
+const DELETE_ORDER_QUEUE = "deleteOrderQueue"
+const UPDATE_ORDER_STATUS_QUEUE = "updateOrderStatusQueue"
 
 type OrderService struct {
-	conn   *amqp091.Connection
-	dbConn *sql.DB
+	Runtime
 }
 
-func (oS OrderService) Init(dbConn *sql.DB, conn *amqp091.Connection) ServiceRunner {
+func (oS OrderService) Init(rt Runtime) ServiceRunner {
+	rt.topic = TOPIC_ORDER
 	return OrderService{
-		conn:   conn,
-		dbConn: dbConn,
+		Runtime: rt,
 	}
 }
 
 func (oS OrderService) Start() {
-	oS.RegisterAllHandler()
+	oS.RegisterAllSubscriber()
+	go relayOutbox(oS.Runtime)
 }
 
 func (oS OrderService) RegisterAllHandler() {
-	go oS.listOrderHandler()
-	go oS.detailOrderHandler()
-	go oS.processOrderHandler()
+	go Handle(oS.Runtime, LIST_ORDER_QUEUE, oS.listOrder)
+	go Handle(oS.Runtime, DETAIL_ORDER_QUEUE, oS.detailOrder)
+	go Handle(oS.Runtime, PROCESS_ORDER_QUEUE, oS.processOrder)
+	go Handle(oS.Runtime, DELETE_ORDER_QUEUE, oS.deleteOrder)
+	go Handle(oS.Runtime, UPDATE_ORDER_STATUS_QUEUE, oS.updateOrderStatus)
+}
+
+func (oS OrderService) RegisterAllSubscriber() {
+	go Subscribe(oS.Runtime, oS.refundOrder)
+	go Subscribe(oS.Runtime, oS.restockCancelledOrder)
 }
 
 func (oS OrderService) Name() string {
 	return "order-service"
 }
 
-func (oS OrderService) listOrderHandler() {
-	log.Printf("%s Handler Registered\n", LIST_ORDER_QUEUE)
-	ch := newChannel(oS.conn, TOPIC_ORDER)
-	msgs := listen(ch, TOPIC_ORDER, LIST_ORDER_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", LIST_ORDER_QUEUE, d.CorrelationId)
-			var orders []schemas.Order
-			rows, err := oS.dbConn.Query(
-				`SELECT uuid, user_uuid, amount, created FROM "order" WHERE user_uuid = $1`,
-				string(d.Body),
-			)
-			if err != nil {
-				log.Println(err)
-			} else {
-				for rows.Next() {
-					var order schemas.Order
-					rows.Scan(&order.Uuid, &order.UserUuid, &order.Amount, &order.Created)
-					orders = append(orders, order)
-				}
-			}
-			resp, err := json.Marshal(orders)
-			if err != nil {
-				log.Println(err)
-			}
-			reply(ch, d, resp)
-		}
-	}()
-	<-forever
+func (oS OrderService) listOrder(ctx context.Context, userUuid string) ([]schemas.Order, error) {
+	var orders []schemas.Order
+	rows, err := oS.dbConn.QueryContext(
+		ctx,
+		`SELECT uuid, user_uuid, amount, status, refund_status, created,
+			COALESCE(transaction_uuid::text, ''), COALESCE(payment_reference, '') FROM "order" WHERE user_uuid = $1`,
+		userUuid,
+	)
+	if err != nil {
+		return orders, dbError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var order schemas.Order
+		rows.Scan(&order.Uuid, &order.UserUuid, &order.Amount, &order.Status, &order.RefundStatus, &order.Created, &order.TransactionUuid, &order.PaymentReference)
+		orders = append(orders, order)
+	}
+	return orders, nil
 }
-func (oS OrderService) detailOrderHandler() {
-	log.Printf("%s Handler Registered\n", DETAIL_ORDER_QUEUE)
-	ch := newChannel(oS.conn, TOPIC_ORDER)
-	msgs := listen(ch, TOPIC_ORDER, DETAIL_ORDER_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", DETAIL_ORDER_QUEUE, d.CorrelationId)
-			order := schemas.Order{}
-			json.Unmarshal(d.Body, &order)
-
-			row := oS.dbConn.QueryRow(
-				`SELECT uuid, user_uuid, amount, created FROM "order" WHERE user_uuid = $1 AND uuid = $2`,
-				order.UserUuid,
-				order.Uuid,
-			)
-
-			err := row.Scan(&order.Uuid, &order.UserUuid, &order.Amount, &order.Created)
-			if err != nil {
-				log.Println(err)
-				reply(ch, d, nil)
-				continue
-			}
-			rows, err := oS.dbConn.Query(
-				`SELECT order_uuid, product_uuid, name, description, price, qty FROM "order_item" WHERE order_uuid = $1`,
-				order.Uuid,
-			)
-			if err != nil {
-				log.Println(err)
-				reply(ch, d, nil)
-				continue
-			}
-			for rows.Next() {
-				var ord schemas.OrderItem
-				rows.Scan(&ord.OrderUuid, &ord.ProductUuid, &ord.Name, &ord.Description, &ord.Price, &ord.Qty)
-				order.Items = append(order.Items, ord)
-			}
-			resp, _ := json.Marshal(order)
-			reply(ch, d, resp)
-		}
-	}()
-	<-forever
+
+func (oS OrderService) detailOrder(ctx context.Context, order schemas.Order) (schemas.Order, error) {
+	row := oS.dbConn.QueryRowContext(
+		ctx,
+		`SELECT uuid, user_uuid, amount, status, refund_status, created,
+			COALESCE(transaction_uuid::text, ''), COALESCE(payment_reference, '') FROM "order" WHERE user_uuid = $1 AND uuid = $2`,
+		order.UserUuid,
+		order.Uuid,
+	)
+
+	err := row.Scan(&order.Uuid, &order.UserUuid, &order.Amount, &order.Status, &order.RefundStatus, &order.Created, &order.TransactionUuid, &order.PaymentReference)
+	if err != nil {
+		return order, dbError(err)
+	}
+	rows, err := oS.dbConn.QueryContext(
+		ctx,
+		`SELECT order_uuid, product_uuid, name, description, price, qty FROM "order_item" WHERE order_uuid = $1`,
+		order.Uuid,
+	)
+	if err != nil {
+		return order, dbError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ord schemas.OrderItem
+		rows.Scan(&ord.OrderUuid, &ord.ProductUuid, &ord.Name, &ord.Description, &ord.Price, &ord.Qty)
+		order.Items = append(order.Items, ord)
+	}
+	order.History, err = oS.orderHistory(ctx, order.Uuid)
+	if err != nil {
+		return order, err
+	}
+	return order, nil
 }
-func (oS OrderService) processOrderHandler() {
-	log.Printf("%s Handler Registered\n", PROCESS_ORDER_QUEUE)
-	ch := newChannel(oS.conn, TOPIC_ORDER)
-	msgs := listen(ch, TOPIC_ORDER, PROCESS_ORDER_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", PROCESS_ORDER_QUEUE, d.CorrelationId)
-
-			order := schemas.Order{}
-			json.Unmarshal(d.Body, &order)
-
-			tx, err := oS.dbConn.Begin()
-			if err != nil {
-				log.Println(err)
-				reply(ch, d, nil)
-				continue
-			}
-			uuidV4 := uuid.NewString()
-			row := tx.QueryRow(
-				`INSERT INTO "order" (uuid, user_uuid, amount, created) VALUES (
-					$1,
-					$2,
-					$3,
-					NOW()
-				) RETURNING uuid, user_uuid, amount, created`,
-				uuidV4,
-				order.UserUuid,
-				order.Amount,
-			)
-			err = row.Scan(&order.Uuid, &order.UserUuid, &order.Amount, &order.Created)
-			if err != nil {
-				log.Println(err)
-				tx.Rollback()
-				reply(ch, d, nil)
-				continue
-			}
-			var values []interface{}
-			var binding []string
-
-			values = append(values, uuidV4)
-			for _, o := range order.Items {
-				var tmpBinding []string
-				tmpBinding = append(tmpBinding, "$1")
-				values = append(values, o.ProductUuid)
-				tmpBinding = append(tmpBinding, fmt.Sprintf("$%d", len(values)))
-				values = append(values, o.Name)
-				tmpBinding = append(tmpBinding, fmt.Sprintf("$%d", len(values)))
-				values = append(values, o.Description)
-				tmpBinding = append(tmpBinding, fmt.Sprintf("$%d", len(values)))
-				values = append(values, o.Price)
-				tmpBinding = append(tmpBinding, fmt.Sprintf("$%d", len(values)))
-				values = append(values, o.Qty)
-				tmpBinding = append(tmpBinding, fmt.Sprintf("$%d", len(values)))
-				bundled := fmt.Sprintf("(%s)", strings.Join(tmpBinding, ","))
-				binding = append(binding, bundled)
-			}
-			// bulk insert
-			row = tx.QueryRow(
-				fmt.Sprintf(`INSERT INTO "order_item" (order_uuid, product_uuid, name, description, price, qty) VALUES %s`, strings.Join(binding, ",")),
-				values...,
-			)
-			if row.Err() != nil {
-				log.Println(row.Err())
-				tx.Rollback()
-				reply(ch, d, nil)
-				continue
-			}
-			tx.Commit()
-			resp, _ := json.Marshal(order)
-			reply(ch, d, resp)
+
+// processOrder creates the order of the paid bill. The bill has a single
+// order, processing it again returns the order created the first time.
+func (oS OrderService) processOrder(ctx context.Context, order schemas.Order) (schemas.Order, error) {
+	if len(order.Items) == 0 {
+		return order, newHandlerError(schemas.CODE_INVALID_REQUEST, "order has no items")
+	}
+	if order.TransactionUuid == "" {
+		return order, newHandlerError(schemas.CODE_INVALID_REQUEST, "order has no transaction")
+	}
+
+	tx, err := oS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return order, dbError(err)
+	}
+	// the caller may choose the uuid so the order can be deleted even when
+	// the reply is lost
+	uuidV4 := order.Uuid
+	if uuidV4 == "" {
+		uuidV4 = uuid.NewString()
+	}
+	row := tx.QueryRow(
+		`INSERT INTO "order" (uuid, user_uuid, amount, status, transaction_uuid, payment_reference, created) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5,
+			$6,
+			NOW()
+		) ON CONFLICT DO NOTHING
+		RETURNING uuid, user_uuid, amount, status, transaction_uuid, payment_reference, created`,
+		uuidV4,
+		order.UserUuid,
+		order.Amount,
+		schemas.ORDER_STATUS_CREATED,
+		order.TransactionUuid,
+		order.PaymentReference,
+	)
+	err = row.Scan(&order.Uuid, &order.UserUuid, &order.Amount, &order.Status, &order.TransactionUuid, &order.PaymentReference, &order.Created)
+	// nothing is returned when the order, or another order of the bill, was
+	// already created
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return oS.orderOfTransaction(ctx, order.TransactionUuid)
+	}
+	if err != nil {
+		tx.Rollback()
+		return order, dbError(err)
+	}
+	var values []interface{}
+	var binding []string
+
+	values = append(values, uuidV4)
+	for _, o := range order.Items {
+		var tmpBinding []string
+		tmpBinding = append(tmpBinding, "$1")
+		values = append(values, o.ProductUuid)
+		tmpBinding = append(tmpBinding, fmt.Sprintf("$%d", len(values)))
+		values = append(values, o.Name)
+		tmpBinding = append(tmpBinding, fmt.Sprintf("$%d", len(values)))
+		values = append(values, o.Description)
+		tmpBinding = append(tmpBinding, fmt.Sprintf("$%d", len(values)))
+		values = append(values, o.Price)
+		tmpBinding = append(tmpBinding, fmt.Sprintf("$%d", len(values)))
+		values = append(values, o.Qty)
+		tmpBinding = append(tmpBinding, fmt.Sprintf("$%d", len(values)))
+		bundled := fmt.Sprintf("(%s)", strings.Join(tmpBinding, ","))
+		binding = append(binding, bundled)
+	}
+	// bulk insert
+	_, err = tx.Exec(
+		fmt.Sprintf(`INSERT INTO "order_item" (order_uuid, product_uuid, name, description, price, qty) VALUES %s`, strings.Join(binding, ",")),
+		values...,
+	)
+	if err != nil {
+		tx.Rollback()
+		return order, dbError(err)
+	}
+	if err = recordOrderStatus(tx, order.Uuid, "", order.Status, ""); err != nil {
+		tx.Rollback()
+		return order, dbError(err)
+	}
+	err = writeEvent(tx, oS.topic, OrderCreated{order})
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return order, dbError(err)
+	}
+	return order, nil
+}
+
+func (oS OrderService) orderOfTransaction(ctx context.Context, transactionUuid string) (schemas.Order, error) {
+	var order schemas.Order
+	row := oS.dbConn.QueryRowContext(ctx, `SELECT uuid, user_uuid FROM "order" WHERE transaction_uuid = $1`, transactionUuid)
+	if err := row.Scan(&order.Uuid, &order.UserUuid); err != nil {
+		return order, dbError(err)
+	}
+	return oS.detailOrder(ctx, order)
+}
+
+// deleteOrder removes an order created by a payment that could not be
+// completed, unlike a cancellation which keeps the order. Deleting an order
+// that does not exist is not an error.
+func (oS OrderService) deleteOrder(ctx context.Context, order schemas.Order) (bool, error) {
+	tx, err := oS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, dbError(err)
+	}
+	_, err = tx.Exec(`DELETE FROM "order_item" WHERE order_uuid = $1`, order.Uuid)
+	if err != nil {
+		tx.Rollback()
+		return false, dbError(err)
+	}
+	_, err = tx.Exec(`DELETE FROM "order_status_history" WHERE order_uuid = $1`, order.Uuid)
+	if err != nil {
+		tx.Rollback()
+		return false, dbError(err)
+	}
+	result, err := tx.Exec(`DELETE FROM "order" WHERE uuid = $1`, order.Uuid)
+	if err != nil {
+		tx.Rollback()
+		return false, dbError(err)
+	}
+	if deleted, _ := result.RowsAffected(); deleted > 0 {
+		err = writeEvent(tx, oS.topic, OrderDeleted{order})
+		if err != nil {
+			tx.Rollback()
+			return false, err
 		}
-	}()
-	<-forever
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, dbError(err)
+	}
+	return true, nil
+}
+
+// refundOrder marks the refund of the bill of the order, a partial refund
+// never follows the order being fully refunded. The status of the order is
+// left to its state machine.
+func (oS OrderService) refundOrder(ctx context.Context, e BillingRefunded) error {
+	if e.OrderUuid == "" {
+		return nil
+	}
+	refundStatus := schemas.ORDER_PARTIALLY_REFUNDED
+	if e.TransactionStatus == schemas.STATUS_REFUNDED {
+		refundStatus = schemas.ORDER_REFUNDED
+	}
+	_, err := oS.dbConn.ExecContext(
+		ctx,
+		`UPDATE "order" SET refund_status = $1 WHERE uuid = $2 AND refund_status <> $3`,
+		refundStatus,
+		e.OrderUuid,
+		schemas.ORDER_REFUNDED,
+	)
+	return err
 }
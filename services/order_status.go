@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+// orderTransitions lists the statuses an order can move to from each status,
+// DELIVERED orders can only be returned and CANCELLED and RETURNED are final.
+var orderTransitions = map[string][]string{
+	schemas.ORDER_STATUS_CREATED:    {schemas.ORDER_STATUS_PROCESSING, schemas.ORDER_STATUS_CANCELLED},
+	schemas.ORDER_STATUS_PROCESSING: {schemas.ORDER_STATUS_SHIPPED, schemas.ORDER_STATUS_CANCELLED},
+	schemas.ORDER_STATUS_SHIPPED:    {schemas.ORDER_STATUS_DELIVERED},
+	schemas.ORDER_STATUS_DELIVERED:  {schemas.ORDER_STATUS_RETURNED},
+}
+
+func canTransitionOrder(from, to string) bool {
+	return contains(orderTransitions[from], to)
+}
+
+// repeatedTransition tells whether moving an order from one status to
+// another repeats a cancellation, which succeeds without changing anything.
+func repeatedTransition(from, to string) bool {
+	return from == to && to == schemas.ORDER_STATUS_CANCELLED
+}
+
+// updateOrderStatus moves the order to the status of the transition when the
+// state machine allows it, the change is recorded in the history of the
+// order.
+func (oS OrderService) updateOrderStatus(ctx context.Context, transition schemas.OrderTransition) (schemas.Order, error) {
+	var order schemas.Order
+	tx, err := oS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return order, dbError(err)
+	}
+	// locked so the order moves one transition at a time
+	row := tx.QueryRow(
+		`SELECT uuid, user_uuid, amount, status, refund_status, created,
+			COALESCE(transaction_uuid::text, ''), COALESCE(payment_reference, '') FROM "order"
+		WHERE uuid = $1 AND ($2 = '' OR user_uuid::text = $2) FOR UPDATE`,
+		transition.OrderUuid,
+		transition.UserUuid,
+	)
+	err = row.Scan(&order.Uuid, &order.UserUuid, &order.Amount, &order.Status, &order.RefundStatus, &order.Created, &order.TransactionUuid, &order.PaymentReference)
+	if err != nil {
+		tx.Rollback()
+		return order, dbError(err)
+	}
+	from := order.Status
+	if repeatedTransition(from, transition.Status) {
+		// the order is returned as is, its refund and stock were already
+		// handled by the first cancellation
+		tx.Rollback()
+		return order, nil
+	}
+	if !canTransitionOrder(from, transition.Status) {
+		tx.Rollback()
+		return order, newHandlerError(schemas.CODE_INVALID_REQUEST, fmt.Sprintf("order can not go from %s to %s", from, transition.Status))
+	}
+	_, err = tx.Exec(`UPDATE "order" SET status = $1 WHERE uuid = $2`, transition.Status, order.Uuid)
+	if err != nil {
+		tx.Rollback()
+		return order, dbError(err)
+	}
+	order.Status = transition.Status
+	if err = recordOrderStatus(tx, order.Uuid, from, order.Status, transition.Note); err != nil {
+		tx.Rollback()
+		return order, dbError(err)
+	}
+	err = writeEvent(tx, oS.topic, OrderStatusChanged{Order: order, From: from, Note: transition.Note})
+	if err != nil {
+		tx.Rollback()
+		return order, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return order, dbError(err)
+	}
+	return order, nil
+}
+
+// recordOrderStatus adds the move of the order from one status to another to
+// its history within tx, from is empty when the order is created.
+func recordOrderStatus(tx *sql.Tx, orderUuid, from, to, note string) error {
+	_, err := tx.Exec(
+		`INSERT INTO "order_status_history" (uuid, order_uuid, from_status, status, note, created) VALUES ($1, $2, $3, $4, $5, $6)`,
+		uuid.NewString(),
+		orderUuid,
+		sql.NullString{String: from, Valid: from != ""},
+		to,
+		note,
+		time.Now(),
+	)
+	return err
+}
+
+func (oS OrderService) orderHistory(ctx context.Context, orderUuid string) ([]schemas.OrderStatusHistory, error) {
+	rows, err := oS.dbConn.QueryContext(
+		ctx,
+		`SELECT COALESCE(from_status, ''), status, note, created FROM "order_status_history" WHERE order_uuid = $1 ORDER BY created`,
+		orderUuid,
+	)
+	if err != nil {
+		return nil, dbError(err)
+	}
+	defer rows.Close()
+	var history []schemas.OrderStatusHistory
+	for rows.Next() {
+		var h schemas.OrderStatusHistory
+		rows.Scan(&h.FromStatus, &h.Status, &h.Note, &h.Created)
+		history = append(history, h)
+	}
+	return history, nil
+}
+
+// restockCancelledOrder asks the product-service to put the stock of the
+// items of the cancelled order back.
+func (oS OrderService) restockCancelledOrder(ctx context.Context, e OrderStatusChanged) error {
+	if e.Status != schemas.ORDER_STATUS_CANCELLED {
+		return nil
+	}
+	rows, err := oS.dbConn.QueryContext(ctx, `SELECT product_uuid, qty FROM "order_item" WHERE order_uuid = $1`, e.Uuid)
+	if err != nil {
+		return err
+	}
+	stockReturn := schemas.StockReturn{OrderUuid: e.Uuid}
+	for rows.Next() {
+		var i schemas.StockReservationItem
+		rows.Scan(&i.ProductUuid, &i.Qty)
+		stockReturn.Items = append(stockReturn.Items, i)
+	}
+	rows.Close()
+	return oS.rpc.Product().RestoreOrderStock(ctx, EventOf(ctx).Id, stockReturn)
+}
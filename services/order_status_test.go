@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+func TestCanTransitionOrder(t *testing.T) {
+	statuses := []string{
+		schemas.ORDER_STATUS_CREATED,
+		schemas.ORDER_STATUS_PROCESSING,
+		schemas.ORDER_STATUS_SHIPPED,
+		schemas.ORDER_STATUS_DELIVERED,
+		schemas.ORDER_STATUS_CANCELLED,
+		schemas.ORDER_STATUS_RETURNED,
+	}
+	allowed := map[[2]string]bool{
+		{schemas.ORDER_STATUS_CREATED, schemas.ORDER_STATUS_PROCESSING}:   true,
+		{schemas.ORDER_STATUS_CREATED, schemas.ORDER_STATUS_CANCELLED}:    true,
+		{schemas.ORDER_STATUS_PROCESSING, schemas.ORDER_STATUS_SHIPPED}:   true,
+		{schemas.ORDER_STATUS_PROCESSING, schemas.ORDER_STATUS_CANCELLED}: true,
+		{schemas.ORDER_STATUS_SHIPPED, schemas.ORDER_STATUS_DELIVERED}:    true,
+		{schemas.ORDER_STATUS_DELIVERED, schemas.ORDER_STATUS_RETURNED}:   true,
+	}
+	// every pair of statuses, staying on the same status included
+	for _, from := range statuses {
+		for _, to := range statuses {
+			want := allowed[[2]string{from, to}]
+			require.Equal(t, want, canTransitionOrder(from, to), "%s -> %s", from, to)
+		}
+	}
+
+	for _, final := range []string{schemas.ORDER_STATUS_CANCELLED, schemas.ORDER_STATUS_RETURNED} {
+		require.Empty(t, orderTransitions[final], "%s must be final", final)
+	}
+	require.False(t, canTransitionOrder("", schemas.ORDER_STATUS_PROCESSING))
+	require.False(t, canTransitionOrder("UNKNOWN", schemas.ORDER_STATUS_CANCELLED))
+	require.False(t, canTransitionOrder(schemas.ORDER_STATUS_CREATED, "UNKNOWN"))
+}
+
+func TestRepeatedTransition(t *testing.T) {
+	require.True(t, repeatedTransition(schemas.ORDER_STATUS_CANCELLED, schemas.ORDER_STATUS_CANCELLED))
+	require.False(t, repeatedTransition(schemas.ORDER_STATUS_CREATED, schemas.ORDER_STATUS_CANCELLED))
+	require.False(t, repeatedTransition(schemas.ORDER_STATUS_SHIPPED, schemas.ORDER_STATUS_SHIPPED))
+	require.False(t, repeatedTransition(schemas.ORDER_STATUS_RETURNED, schemas.ORDER_STATUS_RETURNED))
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"wahyuade.com/simple-e-commerce/broker"
+)
+
+// TOPIC_DOMAIN_EVENTS is the exchange the domain events are published to,
+// with the name of the event as routing key.
+const TOPIC_DOMAIN_EVENTS = "domain-events"
+
+// HEADER_EVENT_ID carries the id of the event, an event may be delivered
+// more than once and the consumers can use it to skip the duplicates.
+const HEADER_EVENT_ID = "x-event-id"
+
+// OUTBOX_RELAY_INTERVAL is how often the outbox is looked for unsent events,
+// at most OUTBOX_BATCH_SIZE of them being published at a time.
+const OUTBOX_RELAY_INTERVAL = time.Second
+const OUTBOX_BATCH_SIZE = 100
+
+// OUTBOX_PUBLISH_TIMEOUT is how long the broker is given to confirm an event.
+const OUTBOX_PUBLISH_TIMEOUT = 5 * time.Second
+
+// Event is the body of the messages published to TOPIC_DOMAIN_EVENTS.
+type Event struct {
+	Id      string          `json:"id"`
+	Name    string          `json:"name"`
+	Source  string          `json:"source"`
+	Created time.Time       `json:"created"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// writeEvent stores the event in the outbox within tx, so it is published if
+// and only if the changes it describes are committed.
+func writeEvent(tx *sql.Tx, source string, event DomainEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO "outbox" (uuid, source, event, payload, created) VALUES ($1, $2, $3, $4, $5)`,
+		uuid.NewString(),
+		source,
+		event.EventName(),
+		string(body),
+		time.Now(),
+	)
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+// relayOutbox publishes the events written by the service of rt until it
+// stops consuming. An event is marked as sent once the broker confirmed it,
+// so it is published again when the service stops in between.
+func relayOutbox(rt Runtime) {
+	log.Printf("Relaying the outbox of %s to %s\n", rt.topic, TOPIC_DOMAIN_EVENTS)
+	ticker := time.NewTicker(OUTBOX_RELAY_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rt.consuming.Done():
+			log.Printf("Outbox relay of %s stopped\n", rt.topic)
+			return
+		case <-ticker.C:
+		}
+		for {
+			n, err := relayBatch(rt)
+			if err != nil {
+				log.Printf("Failed to relay the outbox of %s: %s", rt.topic, err)
+				break
+			}
+			if n < OUTBOX_BATCH_SIZE {
+				break
+			}
+		}
+	}
+}
+
+// relayBatch publishes the oldest unsent events and returns how many were
+// sent. The rows stay locked until they are marked, so several instances of
+// the service never publish the same event at the same time.
+func relayBatch(rt Runtime) (int, error) {
+	// not bound to consuming, the events published right before the
+	// service stops must still be marked
+	tx, err := rt.dbConn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query(
+		`SELECT uuid, event, payload, created FROM "outbox" WHERE source = $1 AND sent IS NULL
+		ORDER BY created LIMIT $2 FOR UPDATE SKIP LOCKED`,
+		rt.topic,
+		OUTBOX_BATCH_SIZE,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var payload string
+		rows.Scan(&e.Id, &e.Name, &payload, &e.Created)
+		e.Source = rt.topic
+		e.Payload = json.RawMessage(payload)
+		events = append(events, e)
+	}
+	rows.Close()
+
+	var sent []string
+	for _, e := range events {
+		if err = publishEvent(rt.transport, e); err != nil {
+			break
+		}
+		sent = append(sent, e.Id)
+	}
+	if len(sent) > 0 {
+		_, mErr := tx.Exec(`UPDATE "outbox" SET sent = $1 WHERE uuid = ANY($2)`, time.Now(), pq.Array(sent))
+		if mErr != nil {
+			return 0, mErr
+		}
+		if mErr = tx.Commit(); mErr != nil {
+			return 0, mErr
+		}
+	}
+	return len(sent), err
+}
+
+func publishEvent(t broker.Transport, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), OUTBOX_PUBLISH_TIMEOUT)
+	defer cancel()
+	return t.Publish(ctx, TOPIC_DOMAIN_EVENTS, broker.Message{
+		RoutingKey: e.Name,
+		Headers: map[string]interface{}{
+			HEADER_EVENT_ID: e.Id,
+		},
+		Body:       body,
+		Persistent: true,
+	})
+}
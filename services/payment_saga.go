@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+const PAYMENT_SAGA = "payment"
+
+// paymentSagaData is persisted along with the payment saga so it can be
+// compensated after a crash.
+type paymentSagaData struct {
+	Payment schemas.PaymentFlag
+	Order   schemas.Order
+}
+
+// paymentSaga records the payment of the bill and, once the bill is fully
+// paid, commits the stock reserved for the bill and creates the order. A
+// failing step undoes the previous ones so a bill is never left paid without
+// its order.
+func (tS TransactionService) paymentSaga() saga[paymentSagaData] {
+	return saga[paymentSagaData]{
+		name:   PAYMENT_SAGA,
+		dbConn: tS.dbConn,
+		steps: []sagaStep[paymentSagaData]{
+			{
+				name:       "process_payment",
+				action:     tS.processPaymentStep,
+				compensate: tS.revertPaymentStep,
+				// a payment refused as already received belongs to another
+				// run, it must not be reverted by this one
+				atomic: true,
+			},
+			{
+				name:       "commit_stock",
+				action:     tS.commitStockStep,
+				compensate: tS.releaseStockStep,
+			},
+			{
+				name:       "process_order",
+				action:     tS.processOrderStep,
+				compensate: tS.deleteOrderStep,
+			},
+		},
+	}
+}
+
+// pay runs the payment saga for pf.
+func (tS TransactionService) pay(ctx context.Context, pf schemas.PaymentFlag) (schemas.PaymentFlag, error) {
+	// chosen up front so the order can be deleted even when the reply of
+	// the order service is lost
+	pf.Transaction.OrderUuid = uuid.NewString()
+	// the payment is reverted by its uuid, so only the payment recorded by
+	// this run is undone, even when the run is recovered after a crash
+	pf.Payment.Uuid = uuid.NewString()
+	data, err := tS.paymentSaga().run(ctx, paymentSagaData{
+		Payment: pf,
+		Order: schemas.Order{
+			Uuid:            pf.Transaction.OrderUuid,
+			UserUuid:        pf.Transaction.UserUuid,
+			Amount:          pf.Transaction.Amount,
+			TransactionUuid: pf.Transaction.Uuid,
+		},
+	})
+	return data.Payment, err
+}
+
+func (tS TransactionService) processPaymentStep(ctx context.Context, run *sagaRun[paymentSagaData]) error {
+	pf, err := tS.processPayment(ctx, run.Data.Payment)
+	if err != nil {
+		return err
+	}
+	run.Data.Payment = pf
+	run.Data.Order.UserUuid = pf.Transaction.UserUuid
+	run.Data.Order.PaymentReference = pf.Payment.Reference
+	return nil
+}
+
+func (tS TransactionService) revertPaymentStep(ctx context.Context, run *sagaRun[paymentSagaData]) error {
+	return tS.revertPayment(ctx, run.Data.Payment)
+}
+
+// fullyPaid tells whether the payment settled the bill, the stock and the
+// order wait for the last payment of a partially paid bill.
+func (d paymentSagaData) fullyPaid() bool {
+	return d.Payment.Transaction.Status == schemas.STATUS_PAID
+}
+
+func (tS TransactionService) commitStockStep(ctx context.Context, run *sagaRun[paymentSagaData]) error {
+	if !run.Data.fullyPaid() {
+		return nil
+	}
+	correlationId := CorrelationId(ctx)
+	err := tS.rpc.Product().CommitStock(ctx, correlationId, run.Data.Payment.Transaction.Uuid)
+	if err != nil {
+		return err
+	}
+	items, err := tS.listTransactionItem(ctx, run.Data.Payment.Transaction.Uuid)
+	if err != nil {
+		return err
+	}
+	run.Data.Order.Items = nil
+	for _, i := range items {
+		product, err := tS.rpc.Product().DetailProduct(ctx, correlationId, i.ProductUuid)
+		if err != nil {
+			return err
+		}
+		run.Data.Order.Items = append(run.Data.Order.Items, schemas.OrderItem{
+			ProductUuid: i.ProductUuid,
+			Name:        product.Name,
+			Description: product.Description,
+			Price:       product.Price,
+			Qty:         i.Qty,
+		})
+	}
+	return nil
+}
+
+func (tS TransactionService) releaseStockStep(ctx context.Context, run *sagaRun[paymentSagaData]) error {
+	return tS.rpc.Product().ReleaseStock(ctx, CorrelationId(ctx), run.Data.Payment.Transaction.Uuid)
+}
+
+func (tS TransactionService) processOrderStep(ctx context.Context, run *sagaRun[paymentSagaData]) error {
+	if !run.Data.fullyPaid() {
+		return nil
+	}
+	// an order created by an earlier attempt whose reply was lost is
+	// returned again
+	order, err := tS.rpc.Order().ProcessOrder(ctx, CorrelationId(ctx), run.Data.Order)
+	if err != nil {
+		return err
+	}
+	run.Data.Order = order
+	return nil
+}
+
+func (tS TransactionService) deleteOrderStep(ctx context.Context, run *sagaRun[paymentSagaData]) error {
+	return tS.rpc.Order().DeleteOrder(ctx, CorrelationId(ctx), run.Data.Order)
+}
+
+// revertPayment removes the payment recorded with the uuid of pf along with
+// the credit it made, the bill is set back to UNPAID or PARTIALLY_PAID by what
+// is left paid. Nothing is changed when the payment was not recorded.
+func (tS TransactionService) revertPayment(ctx context.Context, pf schemas.PaymentFlag) error {
+	if pf.Payment.Uuid == "" {
+		return nil
+	}
+	tx, err := tS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return dbError(err)
+	}
+	var amount int
+	var reference string
+	row := tx.QueryRow(
+		`DELETE FROM "transaction_payment" WHERE uuid = $1 AND transaction_uuid = $2 RETURNING amount, reference`,
+		pf.Payment.Uuid,
+		pf.Transaction.Uuid,
+	)
+	err = row.Scan(&amount, &reference)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return nil
+	}
+	if err != nil {
+		tx.Rollback()
+		return dbError(err)
+	}
+	_, err = tx.Exec(
+		`DELETE FROM "customer_credit" WHERE transaction_uuid = $1 AND reference = $2`,
+		pf.Transaction.Uuid,
+		reference,
+	)
+	if err != nil {
+		tx.Rollback()
+		return dbError(err)
+	}
+	_, err = tx.Exec(
+		`UPDATE "transaction" SET
+			paid = paid - $1,
+			status = CASE WHEN paid - $1 > 0 THEN $2 ELSE $3 END,
+			order_uuid = NULL
+		WHERE uuid = $4 AND status IN ($5, $2)`,
+		amount,
+		schemas.STATUS_PARTIALLY_PAID,
+		schemas.STATUS_UNPAID,
+		pf.Transaction.Uuid,
+		schemas.STATUS_PAID,
+	)
+	if err != nil {
+		tx.Rollback()
+		return dbError(err)
+	}
+	err = writeEvent(tx, tS.topic, PaymentReverted{pf})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
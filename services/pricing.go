@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+// TAX_PERCENT is the tax charged on the subtotal of a bill after its
+// discounts, rounded to the nearest rupiah.
+const TAX_PERCENT = 11
+
+// priceCarts turns the carts into lines with the current price of their
+// product, to be totalled by price.
+func (tS TransactionService) priceCarts(ctx context.Context, carts []schemas.TransactionCart) ([]schemas.PriceLine, error) {
+	var lines []schemas.PriceLine
+	for _, c := range carts {
+		rpcCtx, cancel := context.WithTimeout(ctx, RPC_TIMEOUT)
+		product, err := tS.rpc.Product().DetailProduct(rpcCtx, CorrelationId(ctx), c.ProductUuid)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, schemas.PriceLine{
+			ProductUuid: product.Uuid,
+			Name:        product.Name,
+			Price:       product.Price,
+			Qty:         c.Qty,
+		})
+	}
+	return lines, nil
+}
+
+// price computes the totals of the lines, the discounts are given off the
+// subtotal before the tax is computed. The discounts never take the
+// subtotal below zero, the last ones are cut short when they would.
+func price(lines []schemas.PriceLine, discounts ...schemas.PriceDiscount) schemas.PriceBreakdown {
+	var breakdown schemas.PriceBreakdown
+	for _, l := range lines {
+		l.Total = l.Price * l.Qty
+		breakdown.Subtotal += l.Total
+		breakdown.Lines = append(breakdown.Lines, l)
+	}
+	for _, d := range discounts {
+		if left := breakdown.Subtotal - breakdown.Discount; d.Amount > left {
+			d.Amount = left
+		}
+		breakdown.Discount += d.Amount
+		breakdown.Discounts = append(breakdown.Discounts, d)
+	}
+	taxable := breakdown.Subtotal - breakdown.Discount
+	breakdown.Tax = (taxable*TAX_PERCENT + 50) / 100
+	breakdown.Total = taxable + breakdown.Tax
+	return breakdown
+}
+
+// chargeFee adds the fee of the payment provider on top of the bill, it is
+// not taxed.
+func chargeFee(breakdown *schemas.PriceBreakdown, fee int) {
+	breakdown.Fee = fee
+	breakdown.Total += fee
+}
+
+// breakdownColumn stores the breakdown of a transaction as JSON, bills
+// created before the pricing have none.
+type breakdownColumn struct {
+	breakdown **schemas.PriceBreakdown
+}
+
+func (c breakdownColumn) Scan(src interface{}) error {
+	var data []byte
+	switch s := src.(type) {
+	case nil:
+		*c.breakdown = nil
+		return nil
+	case []byte:
+		data = s
+	case string:
+		data = []byte(s)
+	default:
+		return fmt.Errorf("unsupported breakdown column type %T", src)
+	}
+	var breakdown schemas.PriceBreakdown
+	if err := json.Unmarshal(data, &breakdown); err != nil {
+		return err
+	}
+	*c.breakdown = &breakdown
+	return nil
+}
+
+func (c breakdownColumn) Value() (driver.Value, error) {
+	if *c.breakdown == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(*c.breakdown)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
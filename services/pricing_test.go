@@ -0,0 +1,118 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+func TestPrice(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []schemas.PriceLine
+		discounts []schemas.PriceDiscount
+		fee       int
+		// the amount given by each discount after capping
+		discounted []int
+		subtotal   int
+		discount   int
+		tax        int
+		total      int
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:     "quantity",
+			lines:    []schemas.PriceLine{{Price: 10000, Qty: 3}},
+			subtotal: 30000,
+			tax:      3300,
+			total:    33300,
+		},
+		{
+			name:     "several lines",
+			lines:    []schemas.PriceLine{{Price: 10000, Qty: 2}, {Price: 2500, Qty: 4}},
+			subtotal: 30000,
+			tax:      3300,
+			total:    33300,
+		},
+		{
+			name:       "discount before tax",
+			lines:      []schemas.PriceLine{{Price: 10000, Qty: 3}},
+			discounts:  []schemas.PriceDiscount{{Code: "HEMAT", Amount: 5000}},
+			discounted: []int{5000},
+			subtotal:   30000,
+			discount:   5000,
+			tax:        2750,
+			total:      27750,
+		},
+		{
+			name:       "discounts capped at the subtotal",
+			lines:      []schemas.PriceLine{{Price: 10000, Qty: 3}},
+			discounts:  []schemas.PriceDiscount{{Amount: 20000}, {Amount: 15000}, {Amount: 1000}},
+			discounted: []int{20000, 10000, 0},
+			subtotal:   30000,
+			discount:   30000,
+		},
+		{
+			name:     "tax rounded down",
+			lines:    []schemas.PriceLine{{Price: 1004, Qty: 1}},
+			subtotal: 1004,
+			tax:      110,
+			total:    1114,
+		},
+		{
+			name:     "tax rounded up",
+			lines:    []schemas.PriceLine{{Price: 1005, Qty: 1}},
+			subtotal: 1005,
+			tax:      111,
+			total:    1116,
+		},
+		{
+			name:     "tax half rounded up",
+			lines:    []schemas.PriceLine{{Price: 50, Qty: 1}},
+			subtotal: 50,
+			tax:      6,
+			total:    56,
+		},
+		{
+			name:     "fee not taxed",
+			lines:    []schemas.PriceLine{{Price: 10000, Qty: 3}},
+			fee:      4000,
+			subtotal: 30000,
+			tax:      3300,
+			total:    37300,
+		},
+		{
+			name:       "fee charged on a free bill",
+			lines:      []schemas.PriceLine{{Price: 10000, Qty: 1}},
+			discounts:  []schemas.PriceDiscount{{Amount: 15000}},
+			discounted: []int{10000},
+			fee:        4000,
+			subtotal:   10000,
+			discount:   10000,
+			total:      4000,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			breakdown := price(tt.lines, tt.discounts...)
+			chargeFee(&breakdown, tt.fee)
+
+			require.Equal(t, tt.subtotal, breakdown.Subtotal)
+			require.Equal(t, tt.discount, breakdown.Discount)
+			require.Equal(t, tt.tax, breakdown.Tax)
+			require.Equal(t, tt.fee, breakdown.Fee)
+			require.Equal(t, tt.total, breakdown.Total)
+			require.Len(t, breakdown.Lines, len(tt.lines))
+			for i, l := range breakdown.Lines {
+				require.Equal(t, tt.lines[i].Price*tt.lines[i].Qty, l.Total)
+			}
+			require.Len(t, breakdown.Discounts, len(tt.discounted))
+			for i, d := range breakdown.Discounts {
+				require.Equal(t, tt.discounted[i], d.Amount)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"wahyuade.com/simple-e-commerce/pb"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+type productGrpc struct {
+	pb.UnimplementedProductServiceServer
+	pS ProductService
+}
+
+func (pS ProductService) RegisterGrpc(server *grpc.Server) {
+	pb.RegisterProductServiceServer(server, productGrpc{pS: pS})
+}
+
+func (p productGrpc) CreateProduct(ctx context.Context, req *pb.Product) (*pb.Product, error) {
+	product, err := p.pS.createProduct(ctx, schemas.ProductFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.ProductToPB(product), nil
+}
+
+func (p productGrpc) ListProduct(ctx context.Context, req *pb.Empty) (*pb.ProductList, error) {
+	products, err := p.pS.listProduct(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.ProductListToPB(products), nil
+}
+
+func (p productGrpc) DetailProduct(ctx context.Context, req *pb.ProductRequest) (*pb.Product, error) {
+	product, err := p.pS.detailProduct(ctx, req.GetProductUuid())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.ProductToPB(product), nil
+}
+
+func (p productGrpc) ReserveStock(ctx context.Context, req *pb.StockReservation) (*pb.Empty, error) {
+	_, err := p.pS.reserveStock(ctx, schemas.StockReservationFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (p productGrpc) CommitStock(ctx context.Context, req *pb.StockReservation) (*pb.Empty, error) {
+	_, err := p.pS.commitStock(ctx, schemas.StockReservationFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (p productGrpc) ReleaseStock(ctx context.Context, req *pb.StockReservation) (*pb.Empty, error) {
+	_, err := p.pS.releaseStock(ctx, schemas.StockReservationFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (p productGrpc) RestoreOrderStock(ctx context.Context, req *pb.StockReturn) (*pb.Empty, error) {
+	_, err := p.pS.restoreOrderStock(ctx, schemas.StockReturnFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Empty{}, nil
+}
@@ -1,12 +1,9 @@
 package services
 
 import (
-	"database/sql"
-	"encoding/json"
-	"log"
+	"context"
 
 	"github.com/google/uuid"
-	"github.com/rabbitmq/amqp091-go"
 	"wahyuade.com/simple-e-commerce/schemas"
 )
 
@@ -14,156 +11,109 @@ const TOPIC_PRODUCT = "product-service"
 const CREATE_PRODUCT_QUEUE = "createProduct"
 const LIST_PRODUCT_QUEUE = "listProduct"
 const DETAIL_PRODUCT_QUEUE = "detailProduct"
-const DECREMENT_PRODUCT_STOCK_QUEUE = "decrementProductStock"
 
 type ProductService struct {
-	conn   *amqp091.Connection
-	dbConn *sql.DB
+	Runtime
 }
 
-func (pS ProductService) Init(dbConn *sql.DB, conn *amqp091.Connection) ServiceRunner {
+func (pS ProductService) Init(rt Runtime) ServiceRunner {
+	rt.topic = TOPIC_PRODUCT
 	return ProductService{
-		conn:   conn,
-		dbConn: dbConn,
+		Runtime: rt,
 	}
 }
 
 func (pS ProductService) Start() {
-	pS.RegisterAllHandler()
+	pS.RegisterAllSubscriber()
+	go relayOutbox(pS.Runtime)
+	go pS.releaseExpiredReservations()
 }
 
 func (pS ProductService) RegisterAllHandler() {
-	go pS.createProductHandler()
-	go pS.listProductHandler()
-	go pS.detailProductHandler()
-	go pS.decrementProductStockHandler()
+	go Handle(pS.Runtime, CREATE_PRODUCT_QUEUE, pS.createProduct)
+	go Handle(pS.Runtime, LIST_PRODUCT_QUEUE, func(ctx context.Context, _ struct{}) ([]schemas.Product, error) {
+		return pS.listProduct(ctx)
+	})
+	go Handle(pS.Runtime, DETAIL_PRODUCT_QUEUE, pS.detailProduct)
+	go Handle(pS.Runtime, RESERVE_STOCK_QUEUE, pS.reserveStock)
+	go Handle(pS.Runtime, COMMIT_STOCK_QUEUE, pS.commitStock)
+	go Handle(pS.Runtime, RELEASE_STOCK_QUEUE, pS.releaseStock)
+	go Handle(pS.Runtime, RESTORE_ORDER_STOCK_QUEUE, pS.restoreOrderStock)
+}
+
+func (pS ProductService) RegisterAllSubscriber() {
+	go Subscribe(pS.Runtime, func(ctx context.Context, e BillingCancelled) error {
+		_, err := pS.releaseStock(ctx, schemas.StockReservation{TransactionUuid: e.Uuid})
+		return err
+	})
+	go Subscribe(pS.Runtime, func(ctx context.Context, e BillingRefunded) error {
+		return pS.restoreRefundedStock(ctx, e.TransactionRefund)
+	})
 }
 
 func (pS ProductService) Name() string {
 	return "product-service"
 }
 
-func (pS ProductService) createProductHandler() {
-	log.Printf("%s Handler Registered\n", CREATE_PRODUCT_QUEUE)
-	ch := newChannel(pS.conn, TOPIC_PRODUCT)
-	msgs := listen(ch, TOPIC_PRODUCT, CREATE_PRODUCT_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", CREATE_PRODUCT_QUEUE, d.CorrelationId)
-			product := schemas.Product{}
-			json.Unmarshal(d.Body, &product)
-
-			uuidV4 := uuid.NewString()
-			product.Uuid = uuidV4
+func (pS ProductService) createProduct(ctx context.Context, product schemas.Product) (schemas.Product, error) {
+	uuidV4 := uuid.NewString()
+	product.Uuid = uuidV4
 
-			row := pS.dbConn.QueryRow(
-				`INSERT INTO "product"(uuid, name, description, stock, price) VALUES ($1, $2, $3, $4, $5) RETURNING 1`,
-				product.Uuid,
-				product.Name,
-				product.Description,
-				product.Stock,
-				product.Price,
-			)
-			var int int
-			err := row.Scan(&int)
-			if err != nil {
-				log.Println(err)
-				product.Uuid = ""
-			}
-			resp, _ := json.Marshal(product)
-			reply(ch, d, resp)
-		}
-	}()
-	<-forever
-}
-func (pS ProductService) listProductHandler() {
-	log.Printf("%s Handler Registered\n", LIST_PRODUCT_QUEUE)
-	ch := newChannel(pS.conn, TOPIC_PRODUCT)
-	msgs := listen(ch, TOPIC_PRODUCT, LIST_PRODUCT_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", LIST_PRODUCT_QUEUE, d.CorrelationId)
-			var products []schemas.Product
-			rows, err := pS.dbConn.Query(`SELECT uuid, name, price FROM "product"`)
-			if err != nil {
-				log.Println(err)
-			}
-			for rows.Next() {
-				var product schemas.Product
-				rows.Scan(&product.Uuid, &product.Name, &product.Price)
-				products = append(products, product)
-			}
-			resp, _ := json.Marshal(products)
-			reply(ch, d, resp)
-		}
-	}()
-	<-forever
+	tx, err := pS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return product, dbError(err)
+	}
+	row := tx.QueryRow(
+		`INSERT INTO "product"(uuid, name, description, stock, price) VALUES ($1, $2, $3, $4, $5) RETURNING 1`,
+		product.Uuid,
+		product.Name,
+		product.Description,
+		product.Stock,
+		product.Price,
+	)
+	var int int
+	err = row.Scan(&int)
+	if err != nil {
+		tx.Rollback()
+		return product, dbError(err)
+	}
+	err = writeEvent(tx, pS.topic, ProductCreated{product})
+	if err != nil {
+		tx.Rollback()
+		return product, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return product, dbError(err)
+	}
+	return product, nil
 }
-func (pS ProductService) detailProductHandler() {
-	log.Printf("%s Handler Registered\n", DETAIL_PRODUCT_QUEUE)
-	ch := newChannel(pS.conn, TOPIC_PRODUCT)
-	msgs := listen(ch, TOPIC_PRODUCT, DETAIL_PRODUCT_QUEUE)
-	var forever chan struct{}
 
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", DETAIL_PRODUCT_QUEUE, d.CorrelationId)
-			productUuid := string(d.Body)
-			product := schemas.Product{}
-			row := pS.dbConn.QueryRow(
-				`SELECT uuid, name, description, stock, price FROM "product" WHERE uuid = $1`,
-				productUuid,
-			)
-			err := row.Scan(&product.Uuid, &product.Name, &product.Description, &product.Stock, &product.Price)
-			if err != nil {
-				log.Println(err)
-			}
-			resp, err := json.Marshal(product)
-			if err != nil {
-				log.Println(err)
-			}
-			reply(ch, d, resp)
-		}
-	}()
-	<-forever
+func (pS ProductService) listProduct(ctx context.Context) ([]schemas.Product, error) {
+	var products []schemas.Product
+	rows, err := pS.dbConn.QueryContext(ctx, `SELECT uuid, name, price FROM "product"`)
+	if err != nil {
+		return products, dbError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var product schemas.Product
+		rows.Scan(&product.Uuid, &product.Name, &product.Price)
+		products = append(products, product)
+	}
+	return products, nil
 }
 
-func (pS ProductService) decrementProductStockHandler() {
-	log.Printf("%s Handler Registered\n", DECREMENT_PRODUCT_STOCK_QUEUE)
-	ch := newChannel(pS.conn, TOPIC_PRODUCT)
-	msgs := listen(ch, TOPIC_PRODUCT, DECREMENT_PRODUCT_STOCK_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", DECREMENT_PRODUCT_STOCK_QUEUE, d.CorrelationId)
-			product := schemas.Product{}
-			json.Unmarshal(d.Body, &product)
-
-			tx, err := pS.dbConn.Begin()
-			if err != nil {
-				log.Println(err)
-				reply(ch, d, nil)
-				continue
-			}
-			row := tx.QueryRow(
-				`UPDATE "product" SET stock = stock - $1 WHERE uuid = $2`,
-				product.Stock,
-				product.Uuid,
-			)
-			if row.Err() != nil {
-				log.Println(err)
-				tx.Rollback()
-				reply(ch, d, []byte("false"))
-				continue
-			}
-			tx.Commit()
-			reply(ch, d, []byte("true"))
-		}
-	}()
-	<-forever
+func (pS ProductService) detailProduct(ctx context.Context, productUuid string) (schemas.Product, error) {
+	product := schemas.Product{}
+	row := pS.dbConn.QueryRowContext(
+		ctx,
+		`SELECT uuid, name, description, stock, price FROM "product" WHERE uuid = $1`,
+		productUuid,
+	)
+	err := row.Scan(&product.Uuid, &product.Name, &product.Description, &product.Stock, &product.Price)
+	if err != nil {
+		return product, dbError(err)
+	}
+	return product, nil
 }
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"wahyuade.com/simple-e-commerce/broker"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+// RetryPolicy tells how a request failing with a transient error is retried
+// before it is dead lettered.
+type RetryPolicy struct {
+	MaxAttempts int
+	Delay       time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	Delay:       250 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+}
+
+// retryPolicies overrides the default policy per queue. The gateway waits
+// for the payment and the order while they are retried, so they give up
+// sooner.
+var retryPolicies = map[string]RetryPolicy{
+	PROCESS_PAYMENT_QUEUE: {
+		MaxAttempts: 2,
+		Delay:       500 * time.Millisecond,
+		MaxDelay:    time.Second,
+	},
+	PROCESS_ORDER_QUEUE: {
+		MaxAttempts: 2,
+		Delay:       500 * time.Millisecond,
+		MaxDelay:    time.Second,
+	},
+}
+
+func retryPolicy(queue string) RetryPolicy {
+	if p, ok := retryPolicies[queue]; ok {
+		return p
+	}
+	return DefaultRetryPolicy
+}
+
+// backoff returns the delay before the given retry, doubling on every
+// attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.Delay
+	for i := 0; i < attempt; i++ {
+		delay = delay * 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+// retryable reports whether a request failing with code may succeed later.
+func retryable(code string) bool {
+	return code == schemas.CODE_DATABASE_ERROR
+}
+
+// respond replies to d. Transient failures are retried following the policy
+// of the queue, the reply is only sent once the request succeeds or runs out
+// of attempts, in which case it is dead lettered.
+func respond(t broker.Transport, d broker.Delivery, resp schemas.Reply) {
+	if !retryable(resp.Code) {
+		reply(t, d, resp)
+		return
+	}
+	policy := retryPolicy(d.RoutingKey)
+	attempt := d.Attempt()
+	if attempt+1 < policy.MaxAttempts {
+		delay := policy.backoff(attempt)
+		log.Printf("Retrying %s with correlation-id %s in %s: %s", d.RoutingKey, d.CorrelationId, delay, resp.Message)
+		err := t.Retry(context.Background(), d, delay, resp.Message)
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to retry %s with correlation-id %s: %s", d.RoutingKey, d.CorrelationId, err)
+	}
+	deadLetter(t, d, resp)
+}
+
+// reject replies to a request that can not be decoded and dead letters it
+// since it would fail the same way on every attempt.
+func reject(t broker.Transport, d broker.Delivery, err error) {
+	deadLetter(t, d, invalidRequestReply(err))
+}
+
+func deadLetter(t broker.Transport, d broker.Delivery, resp schemas.Reply) {
+	log.Printf("Dead lettering %s with correlation-id %s after %d attempts: %s", d.RoutingKey, d.CorrelationId, d.Attempt()+1, resp.Message)
+	send(t, d, resp)
+	if err := d.Nack(false); err != nil {
+		log.Printf("Failed to dead letter %s with correlation-id %s: %s", d.RoutingKey, d.CorrelationId, err)
+	}
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const SAGA_RUNNING = "RUNNING"
+const SAGA_COMPLETED = "COMPLETED"
+const SAGA_COMPENSATING = "COMPENSATING"
+const SAGA_COMPENSATED = "COMPENSATED"
+
+// SAGA_FAILED is set when a compensation failed, the saga is compensated
+// again by the recovery.
+const SAGA_FAILED = "FAILED"
+
+const STEP_STARTED = "STARTED"
+const STEP_DONE = "DONE"
+const STEP_FAILED = "FAILED"
+const STEP_COMPENSATED = "COMPENSATED"
+
+// SAGA_STEP_TIMEOUT is the deadline given to every step and compensation.
+const SAGA_STEP_TIMEOUT = 10 * time.Second
+
+// SAGA_RECOVERY_INTERVAL is how often the sagas left unfinished are looked
+// for, and SAGA_STALE_AFTER how long a saga may stay unfinished before it is
+// considered abandoned.
+const SAGA_RECOVERY_INTERVAL = time.Minute
+const SAGA_STALE_AFTER = 2 * time.Minute
+
+// sagaStep is a local transaction of a saga. The compensation undoes the
+// action, it must be idempotent and succeed even when the action was
+// interrupted before committing, since a saga recovered after a crash
+// compensates its unfinished step as well. The action of an atomic step
+// commits all of its work or none of it, so it is not compensated when it
+// fails.
+type sagaStep[T any] struct {
+	name       string
+	action     func(ctx context.Context, run *sagaRun[T]) error
+	compensate func(ctx context.Context, run *sagaRun[T]) error
+	atomic     bool
+}
+
+// saga runs its steps in order and persists their progress, so a checkout
+// interrupted half way is compensated instead of being left inconsistent.
+type saga[T any] struct {
+	name   string
+	dbConn *sql.DB
+	steps  []sagaStep[T]
+}
+
+// sagaRun is an execution of a saga along with the data shared by its steps.
+type sagaRun[T any] struct {
+	saga saga[T]
+	uuid string
+	Data T
+}
+
+// run executes every step with data. When a step fails the steps already
+// done are compensated in reverse order and the error of the step is
+// returned.
+func (s saga[T]) run(ctx context.Context, data T) (T, error) {
+	r := &sagaRun[T]{saga: s, uuid: uuid.NewString(), Data: data}
+	payload, err := json.Marshal(r.Data)
+	if err != nil {
+		return r.Data, err
+	}
+	_, err = s.dbConn.ExecContext(
+		ctx,
+		`INSERT INTO "saga" (uuid, name, status, data, created, updated) VALUES ($1, $2, $3, $4, $5, $5)`,
+		r.uuid,
+		s.name,
+		SAGA_RUNNING,
+		string(payload),
+		time.Now(),
+	)
+	if err != nil {
+		return r.Data, dbError(err)
+	}
+	log.Printf("Saga %s %s started with correlation-id: %s", s.name, r.uuid, CorrelationId(ctx))
+
+	for i, step := range s.steps {
+		if err := r.setStep(ctx, step.name, STEP_STARTED, nil); err != nil {
+			r.compensate(ctx, i, err)
+			return r.Data, err
+		}
+		stepCtx, cancel := context.WithTimeout(ctx, SAGA_STEP_TIMEOUT)
+		err := step.action(stepCtx, r)
+		cancel()
+		if err != nil {
+			log.Printf("Saga %s %s failed on %s: %s", s.name, r.uuid, step.name, err)
+			r.setStep(ctx, step.name, STEP_FAILED, err)
+			// the failed step may have committed part of its work
+			n := i + 1
+			if step.atomic {
+				n = i
+			}
+			r.compensate(ctx, n, err)
+			return r.Data, err
+		}
+		err = r.save(ctx)
+		if err == nil {
+			err = r.setStep(ctx, step.name, STEP_DONE, nil)
+		}
+		if err != nil {
+			r.compensate(ctx, i+1, err)
+			return r.Data, err
+		}
+	}
+	r.setStatus(ctx, SAGA_COMPLETED, nil)
+	log.Printf("Saga %s %s completed", s.name, r.uuid)
+	return r.Data, nil
+}
+
+// save persists the data of the run, steps call it to record the progress
+// their compensation relies on.
+func (r *sagaRun[T]) save(ctx context.Context) error {
+	payload, err := json.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = r.saga.dbConn.ExecContext(
+		ctx,
+		`UPDATE "saga" SET data = $1, updated = $2 WHERE uuid = $3`,
+		string(payload),
+		time.Now(),
+		r.uuid,
+	)
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (r *sagaRun[T]) setStep(ctx context.Context, name, status string, stepErr error) error {
+	_, err := r.saga.dbConn.ExecContext(
+		ctx,
+		`INSERT INTO "saga_step" (saga_uuid, name, status, error, updated) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (saga_uuid, name) DO UPDATE SET status = EXCLUDED.status, error = EXCLUDED.error, updated = EXCLUDED.updated`,
+		r.uuid,
+		name,
+		status,
+		errorText(stepErr),
+		time.Now(),
+	)
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+func (r *sagaRun[T]) setStatus(ctx context.Context, status string, sagaErr error) error {
+	_, err := r.saga.dbConn.ExecContext(
+		ctx,
+		`UPDATE "saga" SET status = $1, error = COALESCE($2, error), updated = $3 WHERE uuid = $4`,
+		status,
+		errorText(sagaErr),
+		time.Now(),
+		r.uuid,
+	)
+	if err != nil {
+		log.Printf("Failed to set saga %s %s to %s: %s", r.saga.name, r.uuid, status, err)
+		return dbError(err)
+	}
+	return nil
+}
+
+// compensate undoes the first n steps in reverse order. It runs on its own
+// context so the compensation is not cut short by the request that failed.
+func (r *sagaRun[T]) compensate(ctx context.Context, n int, cause error) {
+	ctx = context.WithValue(context.Background(), correlationIdKey{}, CorrelationId(ctx))
+	r.setStatus(ctx, SAGA_COMPENSATING, cause)
+	for i := n - 1; i >= 0; i-- {
+		step := r.saga.steps[i]
+		if step.compensate == nil {
+			continue
+		}
+		stepCtx, cancel := context.WithTimeout(ctx, SAGA_STEP_TIMEOUT)
+		err := step.compensate(stepCtx, r)
+		cancel()
+		if err == nil {
+			err = r.save(ctx)
+		}
+		if err != nil {
+			log.Printf("Saga %s %s failed to compensate %s: %s", r.saga.name, r.uuid, step.name, err)
+			r.setStatus(ctx, SAGA_FAILED, fmt.Errorf("compensating %s: %w", step.name, err))
+			return
+		}
+		r.setStep(ctx, step.name, STEP_COMPENSATED, nil)
+	}
+	r.setStatus(ctx, SAGA_COMPENSATED, nil)
+	log.Printf("Saga %s %s compensated", r.saga.name, r.uuid)
+}
+
+// recoverStale compensates the sagas abandoned by a crashed service or
+// whose compensation failed, until ctx is done.
+func (s saga[T]) recoverStale(ctx context.Context) {
+	ticker := time.NewTicker(SAGA_RECOVERY_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for {
+			r, statuses, err := s.claim(ctx)
+			if err != nil {
+				log.Printf("Failed to recover the %s sagas: %s", s.name, err)
+				break
+			}
+			if r == nil {
+				break
+			}
+			log.Printf("Recovering saga %s %s", s.name, r.uuid)
+			// every step up to the last one started is compensated, the
+			// ones already compensated are skipped by being idempotent
+			started, done := 0, 0
+			for i, step := range s.steps {
+				status, ok := statuses[step.name]
+				if !ok {
+					break
+				}
+				started = i + 1
+				if status == STEP_DONE {
+					done++
+				}
+			}
+			if done == len(s.steps) {
+				r.setStatus(ctx, SAGA_COMPLETED, nil)
+				continue
+			}
+			r.compensate(context.WithValue(ctx, correlationIdKey{}, r.uuid), started, fmt.Errorf("abandoned for more than %s", SAGA_STALE_AFTER))
+		}
+	}
+}
+
+// claim picks one stale unfinished saga, skipping those claimed by another
+// instance, and returns it with the status of its steps.
+func (s saga[T]) claim(ctx context.Context) (*sagaRun[T], map[string]string, error) {
+	r := &sagaRun[T]{saga: s}
+	var data string
+	row := s.dbConn.QueryRowContext(
+		ctx,
+		`UPDATE "saga" SET updated = $1 WHERE uuid IN (
+			SELECT uuid FROM "saga" WHERE name = $2 AND status IN ($3, $4, $5) AND updated < $6
+			ORDER BY updated LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING uuid, data`,
+		time.Now(),
+		s.name,
+		SAGA_RUNNING,
+		SAGA_COMPENSATING,
+		SAGA_FAILED,
+		time.Now().Add(-SAGA_STALE_AFTER),
+	)
+	err := row.Scan(&r.uuid, &data)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &r.Data); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := s.dbConn.QueryContext(ctx, `SELECT name, status FROM "saga_step" WHERE saga_uuid = $1`, r.uuid)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	statuses := map[string]string{}
+	for rows.Next() {
+		var name, status string
+		rows.Scan(&name, &status)
+		statuses[name] = status
+	}
+	return r, statuses, nil
+}
+
+func errorText(err error) interface{} {
+	if err == nil {
+		return nil
+	}
+	return err.Error()
+}
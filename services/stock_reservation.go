@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+const RESERVE_STOCK_QUEUE = "reserveStock"
+const COMMIT_STOCK_QUEUE = "commitStock"
+const RELEASE_STOCK_QUEUE = "releaseStock"
+const RESTORE_ORDER_STOCK_QUEUE = "restoreOrderStock"
+
+const RESERVATION_RESERVED = "RESERVED"
+const RESERVATION_COMMITTED = "COMMITTED"
+const RESERVATION_RELEASED = "RELEASED"
+
+// RESERVATION_RELEASE_INTERVAL is how often the expired reservations are
+// given back to the stock, at most RESERVATION_RELEASE_BATCH_SIZE at a time.
+const RESERVATION_RELEASE_INTERVAL = time.Minute
+const RESERVATION_RELEASE_BATCH_SIZE = 100
+
+// reserveStock takes the stock of every item out for the transaction, or
+// none of them when one is not available. Reserving a transaction twice
+// does nothing.
+func (pS ProductService) reserveStock(ctx context.Context, reservation schemas.StockReservation) (bool, error) {
+	if len(reservation.Items) == 0 {
+		return false, newHandlerError(schemas.CODE_INVALID_REQUEST, "reservation has no items")
+	}
+	tx, err := pS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, dbError(err)
+	}
+	var reserved bool
+	row := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM "product_reservation" WHERE transaction_uuid = $1)`, reservation.TransactionUuid)
+	if err = row.Scan(&reserved); err != nil {
+		tx.Rollback()
+		return false, dbError(err)
+	}
+	if reserved {
+		tx.Rollback()
+		return true, nil
+	}
+	for _, i := range reservation.Items {
+		if err = pS.takeStock(tx, i.ProductUuid, i.Qty); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+		_, err = tx.Exec(
+			`INSERT INTO "product_reservation" (transaction_uuid, product_uuid, qty, status, expired, updated) VALUES ($1, $2, $3, $4, $5, $6)`,
+			reservation.TransactionUuid,
+			i.ProductUuid,
+			i.Qty,
+			RESERVATION_RESERVED,
+			reservation.Expired,
+			time.Now(),
+		)
+		if err != nil {
+			tx.Rollback()
+			return false, dbError(err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, dbError(err)
+	}
+	return true, nil
+}
+
+// commitStock keeps the reservation of the transaction for good. A
+// reservation released in the meantime takes the stock again if it is still
+// available.
+func (pS ProductService) commitStock(ctx context.Context, reservation schemas.StockReservation) (bool, error) {
+	tx, err := pS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, dbError(err)
+	}
+	// locked first so the expired reservations are not released under us
+	rows, err := tx.Query(
+		`SELECT product_uuid, qty, status FROM "product_reservation" WHERE transaction_uuid = $1 FOR UPDATE`,
+		reservation.TransactionUuid,
+	)
+	if err != nil {
+		tx.Rollback()
+		return false, dbError(err)
+	}
+	var items []schemas.StockReservationItem
+	var statuses []string
+	for rows.Next() {
+		var i schemas.StockReservationItem
+		var status string
+		rows.Scan(&i.ProductUuid, &i.Qty, &status)
+		items = append(items, i)
+		statuses = append(statuses, status)
+	}
+	rows.Close()
+	if len(items) == 0 {
+		tx.Rollback()
+		return false, newHandlerError(schemas.CODE_NOT_FOUND, "reservation not found")
+	}
+	for n, i := range items {
+		if statuses[n] != RESERVATION_RELEASED {
+			continue
+		}
+		if err = pS.takeStock(tx, i.ProductUuid, i.Qty); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+	_, err = tx.Exec(
+		`UPDATE "product_reservation" SET status = $1, updated = $2 WHERE transaction_uuid = $3`,
+		RESERVATION_COMMITTED,
+		time.Now(),
+		reservation.TransactionUuid,
+	)
+	if err != nil {
+		tx.Rollback()
+		return false, dbError(err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, dbError(err)
+	}
+	return true, nil
+}
+
+// releaseStock gives the stock held by the transaction back, whether it was
+// committed or not. Releasing a transaction twice does nothing.
+func (pS ProductService) releaseStock(ctx context.Context, reservation schemas.StockReservation) (bool, error) {
+	_, err := pS.dbConn.ExecContext(
+		ctx,
+		`WITH released AS (
+			UPDATE "product_reservation" SET status = $1, updated = $2
+			WHERE transaction_uuid = $3 AND status IN ($4, $5)
+			RETURNING product_uuid, qty
+		)
+		UPDATE "product" SET stock = stock + released.qty FROM released WHERE "product".uuid = released.product_uuid`,
+		RESERVATION_RELEASED,
+		time.Now(),
+		reservation.TransactionUuid,
+		RESERVATION_RESERVED,
+		RESERVATION_COMMITTED,
+	)
+	if err != nil {
+		return false, dbError(err)
+	}
+	return true, nil
+}
+
+// restoreRefundedStock puts the stock of the items of the refund back. Each
+// refund is restored once however many times its event is delivered.
+func (pS ProductService) restoreRefundedStock(ctx context.Context, refund schemas.TransactionRefund) error {
+	tx, err := pS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, i := range refund.Items {
+		result, err := tx.Exec(
+			`INSERT INTO "product_stock_return" (return_uuid, product_uuid, transaction_uuid, order_uuid, qty, created) VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT DO NOTHING`,
+			refund.Uuid,
+			i.ProductUuid,
+			refund.TransactionUuid,
+			sql.NullString{String: refund.OrderUuid, Valid: refund.OrderUuid != ""},
+			i.Qty,
+			time.Now(),
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		_, err = tx.Exec(`UPDATE "product" SET stock = stock + $1 WHERE uuid = $2`, i.Qty, i.ProductUuid)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// restoreOrderStock puts the stock of the items of the cancelled order back,
+// less what was put back by the refunds of its bill. The order is restored
+// once however many times it is asked.
+func (pS ProductService) restoreOrderStock(ctx context.Context, stockReturn schemas.StockReturn) (bool, error) {
+	tx, err := pS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, dbError(err)
+	}
+	for _, i := range stockReturn.Items {
+		// the uuid of the order is the one of its return
+		var qty int
+		row := tx.QueryRow(
+			`INSERT INTO "product_stock_return" (return_uuid, product_uuid, order_uuid, qty, created)
+			SELECT $1, $2, $1, $3 - COALESCE(SUM(qty), 0), $4::timestamp FROM "product_stock_return"
+			WHERE order_uuid = $1 AND product_uuid = $2 AND return_uuid <> $1
+			HAVING $3 - COALESCE(SUM(qty), 0) > 0
+			ON CONFLICT DO NOTHING RETURNING qty`,
+			stockReturn.OrderUuid,
+			i.ProductUuid,
+			i.Qty,
+			time.Now(),
+		)
+		err = row.Scan(&qty)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			tx.Rollback()
+			return false, dbError(err)
+		}
+		_, err = tx.Exec(`UPDATE "product" SET stock = stock + $1 WHERE uuid = $2`, qty, i.ProductUuid)
+		if err != nil {
+			tx.Rollback()
+			return false, dbError(err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, dbError(err)
+	}
+	return true, nil
+}
+
+// takeStock decrements the stock of the product within tx, failing when
+// there is not enough of it.
+func (pS ProductService) takeStock(tx *sql.Tx, productUuid string, qty int) error {
+	var product schemas.Product
+	row := tx.QueryRow(
+		`UPDATE "product" SET stock = stock - $1 WHERE uuid = $2 AND stock >= $1 RETURNING uuid, name, description, stock, price`,
+		qty,
+		productUuid,
+	)
+	err := row.Scan(&product.Uuid, &product.Name, &product.Description, &product.Stock, &product.Price)
+	if errors.Is(err, sql.ErrNoRows) {
+		return newHandlerError(schemas.CODE_INVALID_REQUEST, fmt.Sprintf("stock of %s is not enough", productUuid))
+	}
+	if err != nil {
+		return dbError(err)
+	}
+	if product.Stock == 0 {
+		return writeEvent(tx, pS.topic, StockDepleted{product})
+	}
+	return nil
+}
+
+// releaseExpiredReservations gives the stock of the bills left unpaid back
+// until the service stops consuming.
+func (pS ProductService) releaseExpiredReservations() {
+	ticker := time.NewTicker(RESERVATION_RELEASE_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pS.consuming.Done():
+			return
+		case <-ticker.C:
+		}
+		for {
+			result, err := pS.dbConn.Exec(
+				`WITH released AS (
+					UPDATE "product_reservation" SET status = $1, updated = $2
+					WHERE (transaction_uuid, product_uuid) IN (
+						SELECT transaction_uuid, product_uuid FROM "product_reservation"
+						WHERE status = $3 AND expired < $2
+						LIMIT $4 FOR UPDATE SKIP LOCKED
+					)
+					RETURNING product_uuid, qty
+				)
+				UPDATE "product" SET stock = stock + r.qty
+				FROM (SELECT product_uuid, SUM(qty) AS qty FROM released GROUP BY product_uuid) r
+				WHERE "product".uuid = r.product_uuid`,
+				RESERVATION_RELEASED,
+				time.Now(),
+				RESERVATION_RESERVED,
+				RESERVATION_RELEASE_BATCH_SIZE,
+			)
+			if err != nil {
+				log.Printf("Failed to release the expired reservations: %s", err)
+				break
+			}
+			// stops once there is nothing left to release
+			if n, _ := result.RowsAffected(); n == 0 {
+				break
+			}
+		}
+	}
+}
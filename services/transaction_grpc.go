@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"wahyuade.com/simple-e-commerce/pb"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+type transactionGrpc struct {
+	pb.UnimplementedTransactionServiceServer
+	tS TransactionService
+}
+
+func (tS TransactionService) RegisterGrpc(server *grpc.Server) {
+	pb.RegisterTransactionServiceServer(server, transactionGrpc{tS: tS})
+}
+
+func (t transactionGrpc) CheckProductInCart(ctx context.Context, req *pb.TransactionCart) (*pb.TransactionCart, error) {
+	resp, err := t.tS.checkProductInCart(ctx, schemas.TransactionCartFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.TransactionCartToPB(resp), nil
+}
+
+func (t transactionGrpc) InsertProductToCart(ctx context.Context, req *pb.TransactionCart) (*pb.TransactionCart, error) {
+	resp, err := t.tS.insertProductToCart(ctx, schemas.TransactionCartFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.TransactionCartToPB(resp), nil
+}
+
+func (t transactionGrpc) ListCart(ctx context.Context, req *pb.UserRequest) (*pb.TransactionCartList, error) {
+	resp, err := t.tS.listCart(ctx, req.GetUserUuid())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.TransactionCartListToPB(resp), nil
+}
+
+func (t transactionGrpc) UpdateCart(ctx context.Context, req *pb.TransactionCart) (*pb.TransactionCart, error) {
+	resp, err := t.tS.updateCart(ctx, schemas.TransactionCartFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.TransactionCartToPB(resp), nil
+}
+
+func (t transactionGrpc) CreateBilling(ctx context.Context, req *pb.Transaction) (*pb.Transaction, error) {
+	resp, err := t.tS.createBilling(ctx, schemas.TransactionFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.TransactionToPB(resp), nil
+}
+
+func (t transactionGrpc) ListTransactionItem(ctx context.Context, req *pb.TransactionRequest) (*pb.TransactionCartList, error) {
+	resp, err := t.tS.listTransactionItem(ctx, req.GetTransactionUuid())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.TransactionCartListToPB(resp), nil
+}
+
+func (t transactionGrpc) DetailBilling(ctx context.Context, req *pb.Transaction) (*pb.Transaction, error) {
+	resp, err := t.tS.detailBilling(ctx, schemas.TransactionFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.TransactionToPB(resp), nil
+}
+
+func (t transactionGrpc) ProcessPayment(ctx context.Context, req *pb.PaymentFlag) (*pb.PaymentFlag, error) {
+	resp, err := t.tS.pay(ctx, schemas.PaymentFlagFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.PaymentFlagToPB(resp), nil
+}
+
+func (t transactionGrpc) ApplyVoucher(ctx context.Context, req *pb.Transaction) (*pb.Transaction, error) {
+	resp, err := t.tS.applyVoucher(ctx, schemas.TransactionFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.TransactionToPB(resp), nil
+}
+
+func (t transactionGrpc) CancelBilling(ctx context.Context, req *pb.Transaction) (*pb.Transaction, error) {
+	resp, err := t.tS.cancelBilling(ctx, schemas.TransactionFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.TransactionToPB(resp), nil
+}
+
+func (t transactionGrpc) Refund(ctx context.Context, req *pb.TransactionRefund) (*pb.TransactionRefund, error) {
+	resp, err := t.tS.refund(ctx, schemas.TransactionRefundFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.TransactionRefundToPB(resp), nil
+}
@@ -1,14 +1,14 @@
 package services
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"log"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/rabbitmq/amqp091-go"
-	"wahyuade.com/simple-e-commerce/helpers"
+	"github.com/lib/pq"
 	"wahyuade.com/simple-e-commerce/schemas"
 )
 
@@ -22,399 +22,473 @@ const UPDATE_CART_QUEUE = "updateCart"
 const CREATE_BILLING_QUEUE = "createBilling"
 const DETAIL_BILLING_QUEUE = "detailBilling"
 const PROCESS_PAYMENT_QUEUE = "processPayment"
+const APPLY_VOUCHER_QUEUE = "applyVoucher"
+const CANCEL_BILLING_QUEUE = "cancelBilling"
+const REFUND_QUEUE = "refund"
+
+// VA_GENERATION_ATTEMPTS is how many virtual accounts are tried for a bill
+// before giving up when they are all taken.
+const VA_GENERATION_ATTEMPTS = 5
 
 type TransactionService struct {
-	conn   *amqp091.Connection
-	dbConn *sql.DB
+	Runtime
 }
 
-func (tS TransactionService) Init(dbConn *sql.DB, conn *amqp091.Connection) ServiceRunner {
+func (tS TransactionService) Init(rt Runtime) ServiceRunner {
+	rt.topic = TOPIC_TRANSACTION
 	return TransactionService{
-		conn:   conn,
-		dbConn: dbConn,
+		Runtime: rt,
 	}
 }
 
 func (tS TransactionService) Start() {
-	tS.RegisterAllHandler()
+	tS.RegisterAllSubscriber()
+	go tS.paymentSaga().recoverStale(tS.consuming)
+	go relayOutbox(tS.Runtime)
+	go tS.expireBillings()
 }
 
 func (tS TransactionService) RegisterAllHandler() {
-	go tS.checkProductInCartHandler()
-	go tS.insertProductToCartHandler()
-	go tS.listCartHandler()
-	go tS.updateCartHandler()
-	go tS.createBillingHandler()
-	go tS.listTransactionItemHandler()
-	go tS.detailBillingHandler()
-	go tS.processPaymentHandler()
+	go Handle(tS.Runtime, CHECK_PRODUCT_IN_CART_QUEUE, tS.checkProductInCart)
+	go Handle(tS.Runtime, INSERT_PRODUCT_TO_CART_QUEUE, tS.insertProductToCart)
+	go Handle(tS.Runtime, LIST_CART_QUEUE, tS.listCart)
+	go Handle(tS.Runtime, LIST_TRANSACTION_ITEM_QUEUE, tS.listTransactionItem)
+	go Handle(tS.Runtime, UPDATE_CART_QUEUE, tS.updateCart)
+	go Handle(tS.Runtime, CREATE_BILLING_QUEUE, tS.createBilling)
+	go Handle(tS.Runtime, DETAIL_BILLING_QUEUE, tS.detailBilling)
+	go Handle(tS.Runtime, PROCESS_PAYMENT_QUEUE, tS.pay)
+	go Handle(tS.Runtime, APPLY_VOUCHER_QUEUE, tS.applyVoucher)
+	go Handle(tS.Runtime, CANCEL_BILLING_QUEUE, tS.cancelBilling)
+	go Handle(tS.Runtime, REFUND_QUEUE, tS.refund)
+}
+
+func (tS TransactionService) RegisterAllSubscriber() {
+	go Subscribe(tS.Runtime, tS.refundCancelledOrder)
 }
 
 func (tS TransactionService) Name() string {
 	return "transaction-service"
 }
 
-func (tS TransactionService) checkProductInCartHandler() {
-	log.Printf("%s Handler Registered\n", CHECK_PRODUCT_IN_CART_QUEUE)
-	ch := newChannel(tS.conn, TOPIC_TRANSACTION)
-	msgs := listen(ch, TOPIC_TRANSACTION, CHECK_PRODUCT_IN_CART_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", CHECK_PRODUCT_IN_CART_QUEUE, d.CorrelationId)
-			cart := schemas.TransactionCart{}
-			json.Unmarshal(d.Body, &cart)
-
-			tx, err := tS.dbConn.Begin()
-			if err != nil {
-				log.Println(err)
-				reply(ch, d, nil)
-				continue
-			}
-			row := tx.QueryRow(
-				`SELECT user_uuid, product_uuid, qty FROM "transaction_cart" WHERE user_uuid = $1 AND product_uuid=$2 AND transaction_uuid IS NULL FOR UPDATE`,
-				cart.UserUuid,
-				cart.ProductUuid,
-			)
-			err = row.Scan(&cart.UserUuid, &cart.ProductUuid, &cart.Qty)
-			if err != nil {
-				cart.ProductUuid = ""
-				resp, _ := json.Marshal(cart)
-				log.Println(err)
-				tx.Rollback()
-				reply(ch, d, resp)
-				continue
-			}
-			tx.Commit()
-			resp, _ := json.Marshal(cart)
-			reply(ch, d, resp)
-		}
-	}()
-	<-forever
+func (tS TransactionService) checkProductInCart(ctx context.Context, cart schemas.TransactionCart) (schemas.TransactionCart, error) {
+	row := tS.dbConn.QueryRowContext(
+		ctx,
+		`SELECT user_uuid, product_uuid, qty FROM "transaction_cart" WHERE user_uuid = $1 AND product_uuid=$2 AND transaction_uuid IS NULL`,
+		cart.UserUuid,
+		cart.ProductUuid,
+	)
+	err := row.Scan(&cart.UserUuid, &cart.ProductUuid, &cart.Qty)
+	if err != nil {
+		return cart, dbError(err)
+	}
+	return cart, nil
 }
 
-func (tS TransactionService) insertProductToCartHandler() {
-	log.Printf("%s Handler Registered\n", INSERT_PRODUCT_TO_CART_QUEUE)
-	ch := newChannel(tS.conn, TOPIC_TRANSACTION)
-	msgs := listen(ch, TOPIC_TRANSACTION, INSERT_PRODUCT_TO_CART_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", INSERT_PRODUCT_TO_CART_QUEUE, d.CorrelationId)
-			cart := schemas.TransactionCart{}
-			json.Unmarshal(d.Body, &cart)
+func (tS TransactionService) insertProductToCart(ctx context.Context, cart schemas.TransactionCart) (schemas.TransactionCart, error) {
+	tx, err := tS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return cart, dbError(err)
+	}
+	uuidV4 := uuid.NewString()
+	row := tx.QueryRow(
+		`INSERT INTO "transaction_cart" (uuid, user_uuid, product_uuid, qty) VALUES ($1, $2, $3, $4) RETURNING 1`,
+		uuidV4,
+		cart.UserUuid,
+		cart.ProductUuid,
+		cart.Qty,
+	)
+	var status int
+	err = row.Scan(&status)
+	if err != nil {
+		tx.Rollback()
+		return cart, dbError(err)
+	}
+	cart.Uuid = uuidV4
+	err = writeEvent(tx, tS.topic, CartUpdated{cart})
+	if err != nil {
+		tx.Rollback()
+		return cart, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return cart, dbError(err)
+	}
+	return cart, nil
+}
 
-			tx, err := tS.dbConn.Begin()
-			if err != nil {
-				log.Println(err)
-				reply(ch, d, nil)
-				continue
-			}
-			uuidV4 := uuid.NewString()
-			row := tx.QueryRow(
-				`INSERT INTO "transaction_cart" (uuid, user_uuid, product_uuid, qty) VALUES ($1, $2, $3, $4) RETURNING 1`,
-				uuidV4,
-				cart.UserUuid,
-				cart.ProductUuid,
-				cart.Qty,
-			)
-			var status int
-			err = row.Scan(&status)
-			if err != nil {
-				resp, _ := json.Marshal(cart)
-				log.Println(err)
-				tx.Rollback()
-				reply(ch, d, resp)
-				continue
-			}
-			tx.Commit()
-			cart.Uuid = uuidV4
-			resp, _ := json.Marshal(cart)
-			reply(ch, d, resp)
-		}
-	}()
-	<-forever
+func (tS TransactionService) listCart(ctx context.Context, userUuid string) ([]schemas.TransactionCart, error) {
+	var carts []schemas.TransactionCart
+	rows, err := tS.dbConn.QueryContext(ctx, `SELECT uuid, user_uuid, product_uuid, qty FROM "transaction_cart" WHERE user_uuid = $1 AND transaction_uuid IS NULL`, userUuid)
+	if err != nil {
+		return carts, dbError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cart schemas.TransactionCart
+		rows.Scan(&cart.Uuid, &cart.UserUuid, &cart.ProductUuid, &cart.Qty)
+		carts = append(carts, cart)
+	}
+	return carts, nil
 }
 
-func (tS TransactionService) listCartHandler() {
-	log.Printf("%s Handler Registered\n", LIST_CART_QUEUE)
-	ch := newChannel(tS.conn, TOPIC_TRANSACTION)
-	msgs := listen(ch, TOPIC_TRANSACTION, LIST_CART_QUEUE)
-	var forever chan struct{}
+func (tS TransactionService) listTransactionItem(ctx context.Context, transactionUuid string) ([]schemas.TransactionCart, error) {
+	var carts []schemas.TransactionCart
+	rows, err := tS.dbConn.QueryContext(ctx, `SELECT uuid, user_uuid, product_uuid, qty FROM "transaction_cart" WHERE transaction_uuid = $1`, transactionUuid)
+	if err != nil {
+		return carts, dbError(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cart schemas.TransactionCart
+		rows.Scan(&cart.Uuid, &cart.UserUuid, &cart.ProductUuid, &cart.Qty)
+		carts = append(carts, cart)
+	}
+	return carts, nil
+}
 
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", LIST_CART_QUEUE, d.CorrelationId)
-			var carts []schemas.TransactionCart
-			rows, err := tS.dbConn.Query(`SELECT uuid, user_uuid, product_uuid, qty FROM "transaction_cart" WHERE user_uuid = $1 AND transaction_uuid IS NULL`, string(d.Body))
-			if err != nil {
-				log.Println(err)
-			} else {
-				for rows.Next() {
-					var cart schemas.TransactionCart
-					rows.Scan(&cart.Uuid, &cart.UserUuid, &cart.ProductUuid, &cart.Qty)
-					carts = append(carts, cart)
-				}
-			}
-			resp, err := json.Marshal(carts)
-			if err != nil {
-				log.Println(err)
-			}
-			reply(ch, d, resp)
-		}
-	}()
-	<-forever
+func (tS TransactionService) updateCart(ctx context.Context, cart schemas.TransactionCart) (schemas.TransactionCart, error) {
+	tx, err := tS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return cart, dbError(err)
+	}
+	row := tx.QueryRow(
+		`UPDATE "transaction_cart" SET qty = $1 WHERE product_uuid = $2 AND user_uuid = $3 AND transaction_uuid IS NULL RETURNING uuid, user_uuid, product_uuid, qty`,
+		cart.Qty,
+		cart.ProductUuid,
+		cart.UserUuid,
+	)
+	err = row.Scan(&cart.Uuid, &cart.UserUuid, &cart.ProductUuid, &cart.Qty)
+	if err != nil {
+		tx.Rollback()
+		return cart, dbError(err)
+	}
+	err = writeEvent(tx, tS.topic, CartUpdated{cart})
+	if err != nil {
+		tx.Rollback()
+		return cart, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return cart, dbError(err)
+	}
+	return cart, nil
 }
 
-func (tS TransactionService) listTransactionItemHandler() {
-	log.Printf("%s Handler Registered\n", LIST_TRANSACTION_ITEM_QUEUE)
-	ch := newChannel(tS.conn, TOPIC_TRANSACTION)
-	msgs := listen(ch, TOPIC_TRANSACTION, LIST_TRANSACTION_ITEM_QUEUE)
-	var forever chan struct{}
+// createBilling prices the cart of the user and reserves its stock before
+// attaching it to a new bill, the amount sent by the caller is ignored. The
+// voucher sent by the caller, or else the one put on the cart, is redeemed
+// by the bill. The reservation expires along with the bill.
+func (tS TransactionService) createBilling(ctx context.Context, transaction schemas.Transaction) (schemas.Transaction, error) {
+	carts, err := tS.listCart(ctx, transaction.UserUuid)
+	if err != nil {
+		return transaction, err
+	}
+	if len(carts) == 0 {
+		return transaction, newHandlerError(schemas.CODE_NOT_FOUND, "cart is empty")
+	}
+	provider, ok := schemas.PaymentProviderOf(transaction.PaymentMethod)
+	if !ok {
+		return transaction, newHandlerError(schemas.CODE_INVALID_REQUEST, "unknown payment method")
+	}
+	if transaction.VoucherCode == "" {
+		transaction.VoucherCode, err = tS.cartVoucher(ctx, transaction.UserUuid)
+		if err != nil {
+			return transaction, err
+		}
+	}
+	breakdown, err := tS.priceBilling(ctx, transaction.UserUuid, carts, transaction.VoucherCode)
+	if err != nil {
+		return transaction, err
+	}
+	chargeFee(&breakdown, provider.Fee)
+	transaction.Amount = breakdown.Total
+	transaction.Breakdown = &breakdown
+	transaction.Uuid = uuid.NewString()
+	transaction.Created = time.Now()
+	transaction.Expired = transaction.Created.Add(provider.Expiry)
+	reservation := schemas.StockReservation{
+		TransactionUuid: transaction.Uuid,
+		Expired:         transaction.Expired,
+	}
+	var cartUuids []string
+	for _, c := range carts {
+		reservation.Items = append(reservation.Items, schemas.StockReservationItem{
+			ProductUuid: c.ProductUuid,
+			Qty:         c.Qty,
+		})
+		cartUuids = append(cartUuids, c.Uuid)
+	}
+	rpcCtx, cancel := context.WithTimeout(ctx, RPC_TIMEOUT)
+	err = tS.rpc.Product().ReserveStock(rpcCtx, CorrelationId(ctx), reservation)
+	cancel()
+	if err != nil {
+		return transaction, err
+	}
 
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", LIST_TRANSACTION_ITEM_QUEUE, d.CorrelationId)
-			var carts []schemas.TransactionCart
-			rows, err := tS.dbConn.Query(`SELECT uuid, user_uuid, product_uuid, qty FROM "transaction_cart" WHERE transaction_uuid = $1`, string(d.Body))
-			if err != nil {
-				log.Println(err)
-			} else {
-				for rows.Next() {
-					var cart schemas.TransactionCart
-					rows.Scan(&cart.Uuid, &cart.UserUuid, &cart.ProductUuid, &cart.Qty)
-					carts = append(carts, cart)
-				}
-			}
-			resp, err := json.Marshal(carts)
-			if err != nil {
-				log.Println(err)
-			}
-			reply(ch, d, resp)
+	transaction, err = tS.insertBilling(ctx, provider, transaction, cartUuids)
+	if err != nil {
+		// no need to hold the stock until the reservation expires
+		releaseCtx, cancel := context.WithTimeout(context.Background(), RPC_TIMEOUT)
+		defer cancel()
+		if rErr := tS.rpc.Product().ReleaseStock(releaseCtx, CorrelationId(ctx), reservation.TransactionUuid); rErr != nil {
+			log.Printf("Failed to release the stock reserved for %s: %s", reservation.TransactionUuid, rErr)
 		}
-	}()
-	<-forever
+	}
+	return transaction, err
 }
 
-func (tS TransactionService) updateCartHandler() {
-	log.Printf("%s Handler Registered\n", UPDATE_CART_QUEUE)
-	ch := newChannel(tS.conn, TOPIC_TRANSACTION)
-	msgs := listen(ch, TOPIC_TRANSACTION, UPDATE_CART_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", UPDATE_CART_QUEUE, d.CorrelationId)
-			cart := schemas.TransactionCart{}
-			json.Unmarshal(d.Body, &cart)
-
-			tx, err := tS.dbConn.Begin()
-			if err != nil {
-				log.Println(err)
-				reply(ch, d, nil)
-				continue
-			}
-			row := tx.QueryRow(
-				`UPDATE "transaction_cart" SET qty = $1 WHERE product_uuid = $2 AND user_uuid = $3 AND transaction_uuid IS NULL RETURNING uuid, user_uuid, product_uuid, qty`,
-				cart.Qty,
-				cart.ProductUuid,
-				cart.UserUuid,
-			)
-			err = row.Scan(&cart.Uuid, &cart.UserUuid, &cart.ProductUuid, &cart.Qty)
-			if err != nil {
-				log.Println(err)
-				tx.Rollback()
-				reply(ch, d, nil)
-				continue
-			}
-			tx.Commit()
-			resp, _ := json.Marshal(cart)
-			reply(ch, d, resp)
+// insertBilling creates the bill, attaches the carts and redeems the voucher
+// of the bill.
+func (tS TransactionService) insertBilling(ctx context.Context, provider schemas.PaymentProvider, transaction schemas.Transaction, cartUuids []string) (schemas.Transaction, error) {
+	tx, err := tS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return transaction, dbError(err)
+	}
+	transaction, err = insertTransaction(tx, provider, transaction)
+	if err != nil {
+		tx.Rollback()
+		return transaction, err
+	}
+	row := tx.QueryRow(
+		`UPDATE "transaction_cart" SET transaction_uuid = $1 WHERE user_uuid = $2 AND uuid = ANY($3) AND transaction_uuid IS NULL RETURNING 1`,
+		transaction.Uuid,
+		transaction.UserUuid,
+		pq.Array(cartUuids),
+	)
+	var isOne int
+	err = row.Scan(&isOne)
+	if err != nil {
+		tx.Rollback()
+		return transaction, dbError(err)
+	}
+	if transaction.VoucherCode != "" {
+		if err = tS.redeemVoucher(ctx, tx, transaction); err != nil {
+			tx.Rollback()
+			return transaction, err
 		}
-	}()
-	<-forever
+	}
+	err = writeEvent(tx, tS.topic, BillingCreated{transaction})
+	if err != nil {
+		tx.Rollback()
+		return transaction, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return transaction, dbError(err)
+	}
+	return transaction, nil
 }
 
-func (tS TransactionService) createBillingHandler() {
-	log.Printf("%s Handler Registered\n", CREATE_BILLING_QUEUE)
-	ch := newChannel(tS.conn, TOPIC_TRANSACTION)
-	msgs := listen(ch, TOPIC_TRANSACTION, CREATE_BILLING_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", CREATE_BILLING_QUEUE, d.CorrelationId)
-
-			transaction := schemas.Transaction{}
-			json.Unmarshal(d.Body, &transaction)
-
-			tx, err := tS.dbConn.Begin()
-			if err != nil {
-				log.Println(err)
-				reply(ch, d, nil)
-				continue
-			}
-			uuidV4 := uuid.NewString()
-			row := tx.QueryRow(
-				`INSERT INTO "transaction" (uuid, user_uuid, status, amount, payment_method, created, expired, virtual_account) VALUES (
-					$1,
-					$2,
-					$3,
-					$4,
-					$5,
-					$6,
-					$7,
-					$8
-				) RETURNING uuid, user_uuid, status, amount, payment_method, created, expired, virtual_account`,
-				uuidV4,
-				transaction.UserUuid,
-				"UNPAID",
-				transaction.Amount,
-				transaction.PaymentMethod,
-				time.Now(),
-				time.Now().Add(24*time.Hour),
-				helpers.RandomNumber(16),
-			)
-			err = row.Scan(
-				&transaction.Uuid,
-				&transaction.UserUuid,
-				&transaction.Status,
-				&transaction.Amount,
-				&transaction.PaymentMethod,
-				&transaction.Created,
-				&transaction.Expired,
-				&transaction.VirtualAccount,
-			)
-			if err != nil {
-				log.Println(err)
-				tx.Rollback()
-				reply(ch, d, nil)
-				continue
-			}
-			row = tx.QueryRow(
-				`UPDATE "transaction_cart" SET transaction_uuid = $1 WHERE user_uuid = $2 AND transaction_uuid IS NULL RETURNING 1`,
-				transaction.Uuid,
-				transaction.UserUuid,
-			)
-			var isOne int
-			err = row.Scan(&isOne)
-			if err != nil {
-				log.Println(err)
-				tx.Rollback()
-				reply(ch, d, nil)
-				continue
-			}
-			tx.Commit()
-			resp, _ := json.Marshal(transaction)
-			reply(ch, d, resp)
+// insertTransaction inserts the bill within tx with a new virtual account of
+// the provider, trying another one when it is already taken.
+func insertTransaction(tx *sql.Tx, provider schemas.PaymentProvider, transaction schemas.Transaction) (schemas.Transaction, error) {
+	for attempt := 0; attempt < VA_GENERATION_ATTEMPTS; attempt++ {
+		virtualAccount, err := provider.GenerateVirtualAccount()
+		if err != nil {
+			return transaction, err
+		}
+		row := tx.QueryRow(
+			`INSERT INTO "transaction" (uuid, user_uuid, status, amount, payment_method, created, expired, virtual_account, subtotal, discount, tax, fee, breakdown) VALUES (
+				$1,
+				$2,
+				$3,
+				$4,
+				$5,
+				$6,
+				$7,
+				$8,
+				$9,
+				$10,
+				$11,
+				$12,
+				$13
+			) ON CONFLICT (payment_method, virtual_account) DO NOTHING
+			RETURNING uuid, user_uuid, status, amount, payment_method, created, expired, virtual_account, breakdown`,
+			transaction.Uuid,
+			transaction.UserUuid,
+			schemas.STATUS_UNPAID,
+			transaction.Amount,
+			transaction.PaymentMethod,
+			transaction.Created,
+			transaction.Expired,
+			virtualAccount,
+			transaction.Breakdown.Subtotal,
+			transaction.Breakdown.Discount,
+			transaction.Breakdown.Tax,
+			transaction.Breakdown.Fee,
+			breakdownColumn{&transaction.Breakdown},
+		)
+		err = row.Scan(
+			&transaction.Uuid,
+			&transaction.UserUuid,
+			&transaction.Status,
+			&transaction.Amount,
+			&transaction.PaymentMethod,
+			&transaction.Created,
+			&transaction.Expired,
+			&transaction.VirtualAccount,
+			breakdownColumn{&transaction.Breakdown},
+		)
+		// nothing is returned when the virtual account is taken
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Virtual account %s %s is taken, generating another one", transaction.PaymentMethod, virtualAccount)
+			continue
 		}
-	}()
-	<-forever
+		if err != nil {
+			return transaction, dbError(err)
+		}
+		return transaction, nil
+	}
+	return transaction, newHandlerError(schemas.CODE_INTERNAL_ERROR, "no virtual account available")
 }
 
-func (tS TransactionService) detailBillingHandler() {
-	log.Printf("%s Handler Registered\n", DETAIL_BILLING_QUEUE)
-	ch := newChannel(tS.conn, TOPIC_TRANSACTION)
-	msgs := listen(ch, TOPIC_TRANSACTION, DETAIL_BILLING_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", DETAIL_BILLING_QUEUE, d.CorrelationId)
-			transaction := schemas.Transaction{}
+// detailBilling finds the bill by its uuid when it is set, by its virtual
+// account otherwise. The bill has to belong to the user when it is set, the
+// payment notifications of the banks come without one.
+func (tS TransactionService) detailBilling(ctx context.Context, transaction schemas.Transaction) (schemas.Transaction, error) {
+	row := tS.dbConn.QueryRowContext(
+		ctx,
+		`SELECT 
+			uuid, user_uuid, status, amount, payment_method, created, expired, virtual_account, breakdown, COALESCE(order_uuid::text, ''), paid,
+			(SELECT COALESCE(SUM(amount), 0) FROM "customer_credit" WHERE transaction_uuid = "transaction".uuid)
+		FROM "transaction" WHERE
+			(uuid = NULLIF($4, '')::uuid OR ($4 = '' AND payment_method = $1 AND virtual_account = $2))
+			AND ($3 = '' OR user_uuid::text = $3)`,
+		transaction.PaymentMethod,
+		transaction.VirtualAccount,
+		transaction.UserUuid,
+		transaction.Uuid,
+	)
+
+	err := row.Scan(
+		&transaction.Uuid,
+		&transaction.UserUuid,
+		&transaction.Status,
+		&transaction.Amount,
+		&transaction.PaymentMethod,
+		&transaction.Created,
+		&transaction.Expired,
+		&transaction.VirtualAccount,
+		breakdownColumn{&transaction.Breakdown},
+		&transaction.OrderUuid,
+		&transaction.Paid,
+		&transaction.Credit,
+	)
+	if err != nil {
+		return transaction, dbError(err)
+	}
+	return transaction, nil
+}
 
-			json.Unmarshal(d.Body, &transaction)
+// processPayment records a payment of an unpaid or partially paid bill, it
+// is the first step of the payment saga. The bill is PARTIALLY_PAID until the
+// payments add up to its amount, it is then PAID for the order it is for and
+// the part paid over the amount is recorded as credit of the user.
+func (tS TransactionService) processPayment(ctx context.Context, pf schemas.PaymentFlag) (schemas.PaymentFlag, error) {
+	if pf.Payment.Amount <= 0 {
+		return pf, newHandlerError(schemas.CODE_INVALID_REQUEST, "payment amount has to be positive")
+	}
+	tx, err := tS.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return pf, dbError(err)
+	}
 
-			row := tS.dbConn.QueryRow(
-				`SELECT 
-					uuid, user_uuid, status, amount, payment_method, created, expired, virtual_account
-				FROM "transaction" WHERE payment_method = $1 AND virtual_account = $2 AND user_uuid = $3`,
-				transaction.PaymentMethod,
-				transaction.VirtualAccount,
-				transaction.UserUuid,
-			)
+	// locked so the payments of the bill are recorded one at a time
+	var status string
+	var expired time.Time
+	var received bool
+	row := tx.QueryRow(
+		`SELECT user_uuid, status, expired, amount, paid, EXISTS (
+			SELECT 1 FROM "transaction_payment" WHERE transaction_uuid = $1 AND reference = $2
+		) FROM "transaction" WHERE uuid = $1 FOR UPDATE`,
+		pf.Transaction.Uuid,
+		pf.Payment.Reference,
+	)
+	// the credit goes to the user of the bill whoever flagged the payment
+	err = row.Scan(&pf.Transaction.UserUuid, &status, &expired, &pf.Transaction.Amount, &pf.Transaction.Paid, &received)
+	if err != nil {
+		tx.Rollback()
+		return pf, dbError(err)
+	}
+	if err = unpayableError(status, expired, received); err != nil {
+		tx.Rollback()
+		return pf, err
+	}
 
-			err := row.Scan(
-				&transaction.Uuid,
-				&transaction.UserUuid,
-				&transaction.Status,
-				&transaction.Amount,
-				&transaction.PaymentMethod,
-				&transaction.Created,
-				&transaction.Expired,
-				&transaction.VirtualAccount,
-			)
-			if err != nil {
-				log.Println(err)
-				reply(ch, d, nil)
-				continue
-			}
-			resp, _ := json.Marshal(transaction)
-			reply(ch, d, resp)
+	pf.Transaction.Paid += pf.Payment.Amount
+	pf.Transaction.Status = schemas.STATUS_PARTIALLY_PAID
+	orderUuid := sql.NullString{}
+	if pf.Transaction.Paid >= pf.Transaction.Amount {
+		pf.Transaction.Status = schemas.STATUS_PAID
+		orderUuid = sql.NullString{String: pf.Transaction.OrderUuid, Valid: pf.Transaction.OrderUuid != ""}
+	}
+	pf.Transaction.OrderUuid = orderUuid.String
+	_, err = tx.Exec(
+		`UPDATE "transaction" SET status = $1, paid = $2, order_uuid = $3 WHERE uuid = $4`,
+		pf.Transaction.Status,
+		pf.Transaction.Paid,
+		orderUuid,
+		pf.Transaction.Uuid,
+	)
+	if err != nil {
+		tx.Rollback()
+		return pf, dbError(err)
+	}
+	if pf.Payment.Uuid == "" {
+		pf.Payment.Uuid = uuid.NewString()
+	}
+	row = tx.QueryRow(
+		`INSERT INTO "transaction_payment" (uuid, transaction_uuid, reference, amount, payment_datetime) VALUES (
+			$1,
+			$2,
+			$3,
+			$4,
+			$5
+		) RETURNING uuid, transaction_uuid, reference, amount, payment_datetime`,
+		pf.Payment.Uuid,
+		pf.Transaction.Uuid,
+		pf.Payment.Reference,
+		pf.Payment.Amount,
+		pf.Payment.PaymentDatetime,
+	)
+	err = row.Scan(&pf.Payment.Uuid, &pf.Payment.TransactionUuid, &pf.Payment.Reference, &pf.Payment.Amount, &pf.Payment.PaymentDatetime)
+	if err != nil {
+		tx.Rollback()
+		return pf, dbError(err)
+	}
+	if pf.Transaction.Paid > pf.Transaction.Amount {
+		pf.Transaction.Credit = pf.Transaction.Paid - pf.Transaction.Amount
+		err = recordCredit(tx, pf.Transaction.UserUuid, pf.Transaction.Uuid, pf.Payment.Reference, pf.Transaction.Credit, CREDIT_OVERPAYMENT)
+		if err != nil {
+			tx.Rollback()
+			return pf, dbError(err)
 		}
-	}()
-	<-forever
+	}
+	err = writeEvent(tx, tS.topic, PaymentReceived{pf})
+	if err != nil {
+		tx.Rollback()
+		return pf, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return pf, dbError(err)
+	}
+	return pf, nil
 }
 
-func (tS TransactionService) processPaymentHandler() {
-	log.Printf("%s Handler Registered\n", PROCESS_PAYMENT_QUEUE)
-	ch := newChannel(tS.conn, TOPIC_TRANSACTION)
-	msgs := listen(ch, TOPIC_TRANSACTION, PROCESS_PAYMENT_QUEUE)
-	var forever chan struct{}
-
-	go func() {
-		for d := range msgs {
-			log.Printf("Received request for %s with correlation-id: %s", PROCESS_PAYMENT_QUEUE, d.CorrelationId)
-			var pf schemas.PaymentFlag
-			json.Unmarshal(d.Body, &pf)
-
-			tx, err := tS.dbConn.Begin()
-			if err != nil {
-				log.Println(err)
-				reply(ch, d, nil)
-				continue
-			}
-
-			row := tx.QueryRow(
-				`UPDATE "transaction" SET status = 'PAID' WHERE uuid = $1 RETURNING 1`,
-				pf.Transaction.Uuid,
-			)
-			var isSuccessUpdateStatus int
-			err = row.Scan(&isSuccessUpdateStatus)
-			if err != nil {
-				log.Println(err)
-				tx.Rollback()
-				reply(ch, d, nil)
-				continue
-			}
-			uuidV4 := uuid.NewString()
-			row = tx.QueryRow(
-				`INSERT INTO "transaction_payment" (uuid, transaction_uuid, reference, amount, payment_datetime) VALUES (
-					$1,
-					$2,
-					$3,
-					$4,
-					$5
-				) RETURNING uuid, transaction_uuid, reference, amount, payment_datetime`,
-				uuidV4,
-				pf.Transaction.Uuid,
-				pf.Payment.Reference,
-				pf.Payment.Amount,
-				pf.Payment.PaymentDatetime,
-			)
-			err = row.Scan(&pf.Payment.Uuid, &pf.Payment.TransactionUuid, &pf.Payment.Reference, &pf.Payment.Amount, &pf.Payment.PaymentDatetime)
-			if err != nil {
-				log.Println(err)
-				tx.Rollback()
-				reply(ch, d, nil)
-				continue
-			}
-			tx.Commit()
-			resp, _ := json.Marshal(pf)
-			reply(ch, d, resp)
-		}
-	}()
-	<-forever
+// unpayableError tells why a bill with status, expiring at expired, can not
+// take a payment, telling apart the payment already received from another
+// one. It is nil when the bill can be paid.
+func unpayableError(status string, expired time.Time, received bool) error {
+	if received {
+		return newHandlerError(schemas.CODE_DUPLICATE, schemas.PAYMENT_ALREADY_RECEIVED)
+	}
+	payable := status == schemas.STATUS_UNPAID || status == schemas.STATUS_PARTIALLY_PAID
+	if status == schemas.STATUS_EXPIRED || (payable && !time.Now().Before(expired)) {
+		return newHandlerError(schemas.CODE_EXPIRED, "transaction has expired")
+	}
+	if !payable {
+		return newHandlerError(schemas.CODE_DUPLICATE, "transaction is not waiting for payment")
+	}
+	return nil
 }
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"wahyuade.com/simple-e-commerce/pb"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+type userGrpc struct {
+	pb.UnimplementedUserServiceServer
+	uS UserService
+}
+
+func (uS UserService) RegisterGrpc(server *grpc.Server) {
+	pb.RegisterUserServiceServer(server, userGrpc{uS: uS})
+}
+
+func (u userGrpc) GetByEmail(ctx context.Context, req *pb.GetByEmailRequest) (*pb.User, error) {
+	user, err := u.uS.getByEmail(ctx, schemas.User{Email: req.GetEmail()})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.UserToPB(user), nil
+}
+
+func (u userGrpc) Register(ctx context.Context, req *pb.User) (*pb.User, error) {
+	user, err := u.uS.registerUser(ctx, schemas.UserFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.UserToPB(user), nil
+}
+
+func (u userGrpc) SaveSession(ctx context.Context, req *pb.User) (*pb.Empty, error) {
+	_, err := u.uS.setUserSession(ctx, schemas.UserFromPB(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &pb.Empty{}, nil
+}
+
+func (u userGrpc) GetBySession(ctx context.Context, req *pb.GetBySessionRequest) (*pb.User, error) {
+	user, err := u.uS.getUserBySession(ctx, req.GetSession())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return schemas.UserToPB(user), nil
+}
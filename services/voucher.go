@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+const REDEMPTION_REDEEMED = "REDEEMED"
+const REDEMPTION_RELEASED = "RELEASED"
+
+// queryRower is either the connection or a transaction.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// applyVoucher puts the voucher on the cart of the user, to be redeemed by
+// the next bill, and returns the cart priced with it. An empty code takes
+// the voucher off the cart.
+func (tS TransactionService) applyVoucher(ctx context.Context, transaction schemas.Transaction) (schemas.Transaction, error) {
+	carts, err := tS.listCart(ctx, transaction.UserUuid)
+	if err != nil {
+		return transaction, err
+	}
+	if len(carts) == 0 {
+		return transaction, newHandlerError(schemas.CODE_NOT_FOUND, "cart is empty")
+	}
+	breakdown, err := tS.priceBilling(ctx, transaction.UserUuid, carts, transaction.VoucherCode)
+	if err != nil {
+		return transaction, err
+	}
+	if transaction.VoucherCode == "" {
+		_, err = tS.dbConn.ExecContext(ctx, `DELETE FROM "cart_voucher" WHERE user_uuid = $1`, transaction.UserUuid)
+	} else {
+		_, err = tS.dbConn.ExecContext(
+			ctx,
+			`INSERT INTO "cart_voucher" (user_uuid, voucher_code) VALUES ($1, $2)
+			ON CONFLICT (user_uuid) DO UPDATE SET voucher_code = EXCLUDED.voucher_code`,
+			transaction.UserUuid,
+			transaction.VoucherCode,
+		)
+	}
+	if err != nil {
+		return transaction, dbError(err)
+	}
+	transaction.Amount = breakdown.Total
+	transaction.Breakdown = &breakdown
+	return transaction, nil
+}
+
+// priceBilling prices the carts, with the discount of the voucher when code
+// is set.
+func (tS TransactionService) priceBilling(ctx context.Context, userUuid string, carts []schemas.TransactionCart, code string) (schemas.PriceBreakdown, error) {
+	lines, err := tS.priceCarts(ctx, carts)
+	if err != nil {
+		return schemas.PriceBreakdown{}, err
+	}
+	if code == "" {
+		return price(lines), nil
+	}
+	voucher, err := findVoucher(ctx, tS.dbConn, code, false)
+	if err != nil {
+		return schemas.PriceBreakdown{}, err
+	}
+	discount, err := voucherDiscount(voucher, lines, time.Now())
+	if err != nil {
+		return schemas.PriceBreakdown{}, err
+	}
+	if err = checkVoucherLimit(ctx, tS.dbConn, voucher, userUuid); err != nil {
+		return schemas.PriceBreakdown{}, err
+	}
+	return price(lines, discount), nil
+}
+
+// cartVoucher returns the code of the voucher put on the cart of the user,
+// empty when there is none.
+func (tS TransactionService) cartVoucher(ctx context.Context, userUuid string) (string, error) {
+	var code string
+	row := tS.dbConn.QueryRowContext(ctx, `SELECT voucher_code FROM "cart_voucher" WHERE user_uuid = $1`, userUuid)
+	err := row.Scan(&code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", dbError(err)
+	}
+	return code, nil
+}
+
+// redeemVoucher records the use of the voucher by the bill within tx and
+// takes it off the cart. The voucher is locked so its usage limit holds when
+// the user creates several bills at once.
+func (tS TransactionService) redeemVoucher(ctx context.Context, tx *sql.Tx, transaction schemas.Transaction) error {
+	voucher, err := findVoucher(ctx, tx, transaction.VoucherCode, true)
+	if err != nil {
+		return err
+	}
+	if err = checkVoucherLimit(ctx, tx, voucher, transaction.UserUuid); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO "voucher_redemption" (transaction_uuid, voucher_code, user_uuid, amount, status, created, updated) VALUES ($1, $2, $3, $4, $5, $6, $6)`,
+		transaction.Uuid,
+		voucher.Code,
+		transaction.UserUuid,
+		transaction.Breakdown.Discount,
+		REDEMPTION_REDEEMED,
+		time.Now(),
+	)
+	if err != nil {
+		return dbError(err)
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM "cart_voucher" WHERE user_uuid = $1`, transaction.UserUuid)
+	if err != nil {
+		return dbError(err)
+	}
+	return nil
+}
+
+// releaseVoucher gives back the use of the voucher redeemed by the bill
+// within tx, so it counts no more toward the usage limit.
+func releaseVoucher(tx *sql.Tx, transactionUuid string) error {
+	_, err := tx.Exec(
+		`UPDATE "voucher_redemption" SET status = $1, updated = $2 WHERE transaction_uuid = $3 AND status = $4`,
+		REDEMPTION_RELEASED,
+		time.Now(),
+		transactionUuid,
+		REDEMPTION_REDEEMED,
+	)
+	return err
+}
+
+func findVoucher(ctx context.Context, q queryRower, code string, lock bool) (schemas.Voucher, error) {
+	var voucher schemas.Voucher
+	query := `SELECT code, description, type, value, max_discount, min_spend, usage_limit, valid_from, valid_until, product_uuids
+		FROM "voucher" WHERE code = $1`
+	if lock {
+		query += " FOR UPDATE"
+	}
+	row := q.QueryRowContext(ctx, query, code)
+	err := row.Scan(
+		&voucher.Code,
+		&voucher.Description,
+		&voucher.Type,
+		&voucher.Value,
+		&voucher.MaxDiscount,
+		&voucher.MinSpend,
+		&voucher.UsageLimit,
+		&voucher.ValidFrom,
+		&voucher.ValidUntil,
+		pq.Array(&voucher.ProductUuids),
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return voucher, newHandlerError(schemas.CODE_NOT_FOUND, schemas.VOUCHER_NOT_FOUND)
+	}
+	if err != nil {
+		return voucher, dbError(err)
+	}
+	return voucher, nil
+}
+
+// voucherDiscount checks the voucher can be used on the lines at now and
+// computes its discount. A voucher scoped to some products only counts the
+// lines of those products, for its minimum spend as well.
+func voucherDiscount(voucher schemas.Voucher, lines []schemas.PriceLine, now time.Time) (schemas.PriceDiscount, error) {
+	if now.Before(voucher.ValidFrom) {
+		return schemas.PriceDiscount{}, newHandlerError(schemas.CODE_INVALID_REQUEST, schemas.VOUCHER_NOT_STARTED)
+	}
+	if !now.Before(voucher.ValidUntil) {
+		return schemas.PriceDiscount{}, newHandlerError(schemas.CODE_INVALID_REQUEST, schemas.VOUCHER_ENDED)
+	}
+	eligible := 0
+	matched := false
+	for _, l := range lines {
+		if len(voucher.ProductUuids) > 0 && !contains(voucher.ProductUuids, l.ProductUuid) {
+			continue
+		}
+		eligible += l.Price * l.Qty
+		matched = true
+	}
+	if !matched {
+		return schemas.PriceDiscount{}, newHandlerError(schemas.CODE_INVALID_REQUEST, schemas.VOUCHER_NOT_APPLICABLE)
+	}
+	if eligible < voucher.MinSpend {
+		return schemas.PriceDiscount{}, newHandlerError(schemas.CODE_INVALID_REQUEST, schemas.VOUCHER_MIN_SPEND)
+	}
+	var amount int
+	switch voucher.Type {
+	case schemas.VOUCHER_PERCENT:
+		amount = eligible * voucher.Value / 100
+		if voucher.MaxDiscount > 0 && amount > voucher.MaxDiscount {
+			amount = voucher.MaxDiscount
+		}
+	case schemas.VOUCHER_FIXED:
+		amount = voucher.Value
+	default:
+		return schemas.PriceDiscount{}, newHandlerError(schemas.CODE_INTERNAL_ERROR, "unknown voucher type "+voucher.Type)
+	}
+	if amount > eligible {
+		amount = eligible
+	}
+	return schemas.PriceDiscount{
+		Code:        voucher.Code,
+		Description: voucher.Description,
+		Amount:      amount,
+	}, nil
+}
+
+// checkVoucherLimit fails when the user already redeemed the voucher as
+// many times as it allows, the released redemptions do not count.
+func checkVoucherLimit(ctx context.Context, q queryRower, voucher schemas.Voucher, userUuid string) error {
+	if voucher.UsageLimit == 0 {
+		return nil
+	}
+	var redeemed int
+	row := q.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM "voucher_redemption" WHERE voucher_code = $1 AND user_uuid = $2 AND status = $3`,
+		voucher.Code,
+		userUuid,
+		REDEMPTION_REDEEMED,
+	)
+	if err := row.Scan(&redeemed); err != nil {
+		return dbError(err)
+	}
+	if redeemed >= voucher.UsageLimit {
+		return newHandlerError(schemas.CODE_INVALID_REQUEST, schemas.VOUCHER_LIMIT_REACHED)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+func TestVoucherDiscount(t *testing.T) {
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	now := from.Add(time.Hour)
+	lines := []schemas.PriceLine{
+		{ProductUuid: "shirt", Price: 100000, Qty: 2},
+		{ProductUuid: "socks", Price: 15000, Qty: 1},
+	}
+	voucher := func(typ string, value int) schemas.Voucher {
+		return schemas.Voucher{Code: "HEMAT", Type: typ, Value: value, ValidFrom: from, ValidUntil: until}
+	}
+
+	tests := []struct {
+		name    string
+		voucher schemas.Voucher
+		lines   []schemas.PriceLine
+		now     time.Time
+		amount  int
+		err     string
+	}{
+		{
+			name:    "fixed",
+			voucher: voucher(schemas.VOUCHER_FIXED, 20000),
+			amount:  20000,
+		},
+		{
+			name:    "fixed over the lines",
+			voucher: voucher(schemas.VOUCHER_FIXED, 500000),
+			amount:  215000,
+		},
+		{
+			name:    "percent",
+			voucher: voucher(schemas.VOUCHER_PERCENT, 10),
+			amount:  21500,
+		},
+		{
+			name: "percent under the cap",
+			voucher: func() schemas.Voucher {
+				v := voucher(schemas.VOUCHER_PERCENT, 10)
+				v.MaxDiscount = 25000
+				return v
+			}(),
+			amount: 21500,
+		},
+		{
+			name: "percent capped",
+			voucher: func() schemas.Voucher {
+				v := voucher(schemas.VOUCHER_PERCENT, 50)
+				v.MaxDiscount = 25000
+				return v
+			}(),
+			amount: 25000,
+		},
+		{
+			name: "percent of the scoped lines only",
+			voucher: func() schemas.Voucher {
+				v := voucher(schemas.VOUCHER_PERCENT, 10)
+				v.ProductUuids = []string{"socks"}
+				return v
+			}(),
+			amount: 1500,
+		},
+		{
+			name: "fixed capped at the scoped lines",
+			voucher: func() schemas.Voucher {
+				v := voucher(schemas.VOUCHER_FIXED, 20000)
+				v.ProductUuids = []string{"socks"}
+				return v
+			}(),
+			amount: 15000,
+		},
+		{
+			name: "no scoped product in the cart",
+			voucher: func() schemas.Voucher {
+				v := voucher(schemas.VOUCHER_FIXED, 20000)
+				v.ProductUuids = []string{"shoes"}
+				return v
+			}(),
+			err: schemas.VOUCHER_NOT_APPLICABLE,
+		},
+		{
+			name:    "empty cart",
+			voucher: voucher(schemas.VOUCHER_FIXED, 20000),
+			lines:   []schemas.PriceLine{},
+			err:     schemas.VOUCHER_NOT_APPLICABLE,
+		},
+		{
+			name: "min spend reached",
+			voucher: func() schemas.Voucher {
+				v := voucher(schemas.VOUCHER_FIXED, 20000)
+				v.MinSpend = 215000
+				return v
+			}(),
+			amount: 20000,
+		},
+		{
+			name: "min spend not reached",
+			voucher: func() schemas.Voucher {
+				v := voucher(schemas.VOUCHER_FIXED, 20000)
+				v.MinSpend = 215001
+				return v
+			}(),
+			err: schemas.VOUCHER_MIN_SPEND,
+		},
+		{
+			name: "min spend counts the scoped lines only",
+			voucher: func() schemas.Voucher {
+				v := voucher(schemas.VOUCHER_FIXED, 5000)
+				v.MinSpend = 50000
+				v.ProductUuids = []string{"socks"}
+				return v
+			}(),
+			err: schemas.VOUCHER_MIN_SPEND,
+		},
+		{
+			name:    "valid from is included",
+			voucher: voucher(schemas.VOUCHER_FIXED, 20000),
+			now:     from,
+			amount:  20000,
+		},
+		{
+			name:    "before valid from",
+			voucher: voucher(schemas.VOUCHER_FIXED, 20000),
+			now:     from.Add(-time.Nanosecond),
+			err:     schemas.VOUCHER_NOT_STARTED,
+		},
+		{
+			name:    "right before valid until",
+			voucher: voucher(schemas.VOUCHER_FIXED, 20000),
+			now:     until.Add(-time.Nanosecond),
+			amount:  20000,
+		},
+		{
+			name:    "valid until is excluded",
+			voucher: voucher(schemas.VOUCHER_FIXED, 20000),
+			now:     until,
+			err:     schemas.VOUCHER_ENDED,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.lines == nil {
+				tt.lines = lines
+			}
+			if tt.now.IsZero() {
+				tt.now = now
+			}
+			discount, err := voucherDiscount(tt.voucher, tt.lines, tt.now)
+			if tt.err != "" {
+				require.Error(t, err)
+				code, message := errorCode(err)
+				require.Equal(t, schemas.CODE_INVALID_REQUEST, code)
+				require.Equal(t, tt.err, message)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.amount, discount.Amount)
+			require.Equal(t, tt.voucher.Code, discount.Code)
+		})
+	}
+}
@@ -1 +1,312 @@
 package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"wahyuade.com/simple-e-commerce/database"
+	"wahyuade.com/simple-e-commerce/schemas"
+)
+
+type billingModel struct {
+	schemas.Transaction
+	Order *schemas.Order `json:"order"`
+}
+
+type webhookResponseModel struct {
+	Data struct {
+		TransactionUuid string `json:"transaction_uuid"`
+		Reference       string `json:"reference"`
+		Status          string `json:"status"`
+		Paid            int    `json:"paid"`
+		Credit          int    `json:"credit"`
+	} `json:"data"`
+	Errors []grapqlErrorModel `json:"errors"`
+}
+
+// createTestBilling puts a product in the cart of the test user and bills it.
+func createTestBilling(t *testing.T, session string) schemas.Transaction {
+	type mockListProduct struct {
+		Products []schemas.Product `json:"products"`
+	}
+	var products mockListProduct
+	errs := graphqlRequest(t, "product", session, `{
+		products {
+			uuid,
+			stock
+		}
+	}`, &products)
+	require.Equal(t, 0, len(errs))
+
+	productUuid := ""
+	for _, p := range products.Products {
+		if p.Stock > 0 {
+			productUuid = p.Uuid
+			break
+		}
+	}
+	require.NotEqual(t, "", productUuid, "Make sure TestProductMutation_CreateProduct has been run")
+
+	// the product may be left in the cart by an earlier run
+	graphqlRequest(t, "transaction", session, fmt.Sprintf(`mutation {
+		addToCart(product_uuid: "%s", qty: 1) {
+			qty
+		}
+	}`, productUuid), nil)
+
+	type mockCreateBilling struct {
+		CreateBilling schemas.Transaction `json:"createBilling"`
+	}
+	var billing mockCreateBilling
+	errs = graphqlRequest(t, "transaction", session, `mutation {
+		createBilling(payment_method: BCA) {
+			uuid
+			status
+			payment_method
+			amount
+			paid
+			virtual_account
+		}
+	}`, &billing)
+	require.Equal(t, 0, len(errs))
+	require.Equal(t, schemas.STATUS_UNPAID, billing.CreateBilling.Status)
+	require.NotEqual(t, "", billing.CreateBilling.VirtualAccount)
+	return billing.CreateBilling
+}
+
+func detailTestBilling(t *testing.T, session string, trx schemas.Transaction) billingModel {
+	type mockBilling struct {
+		Billing billingModel `json:"billing"`
+	}
+	var billing mockBilling
+	errs := graphqlRequest(t, "transaction", session, fmt.Sprintf(`{
+		billing(payment_method: %s, virtual_account: "%s") {
+			uuid
+			status
+			amount
+			paid
+			credit
+			order_uuid
+			order {
+				uuid
+			}
+		}
+	}`, trx.PaymentMethod, trx.VirtualAccount), &billing)
+	require.Equal(t, 0, len(errs))
+	require.Equal(t, trx.Uuid, billing.Billing.Uuid)
+	return billing.Billing
+}
+
+// notifyTestPayment sends the notification of a payment of amount to the
+// webhook the way the payment provider does.
+func notifyTestPayment(t *testing.T, trx schemas.Transaction, reference string, amount int) (int, webhookResponseModel) {
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	require.NotEqual(t, "", secret, "PAYMENT_WEBHOOK_SECRET must be the one of the server")
+
+	u, err := url.Parse(getUrl(t, "transaction"))
+	require.Equal(t, nil, err)
+	u.Path = "/webhook/payment/" + trx.PaymentMethod
+
+	body, _ := json.Marshal(schemas.PaymentNotification{
+		VirtualAccount: trx.VirtualAccount,
+		Reference:      reference,
+		Amount:         amount,
+		PaidAt:         time.Now(),
+	})
+	now := time.Now()
+	req, _ := http.NewRequest("POST", u.String(), bytes.NewBuffer(body))
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add(schemas.HEADER_NOTIFICATION_TIMESTAMP, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Add(schemas.HEADER_NOTIFICATION_SIGNATURE, schemas.SignPaymentNotification(secret, now, body))
+	resp, err := http.DefaultClient.Do(req)
+	require.Equal(t, nil, err, "Make sure this test can access the server")
+	defer resp.Body.Close()
+
+	var response webhookResponseModel
+	json.NewDecoder(resp.Body).Decode(&response)
+	return resp.StatusCode, response
+}
+
+type simPaymentModel struct {
+	Data struct {
+		Provider       string                      `json:"provider"`
+		Notification   schemas.PaymentNotification `json:"notification"`
+		NotifiedStatus int                         `json:"notified_status"`
+	} `json:"data"`
+}
+
+// simTestRequest posts body to path of the payment gateway simulator, found
+// at PAYGW_SIM_URL or on its default port.
+func simTestRequest(t *testing.T, path string, body interface{}) simPaymentModel {
+	simUrl := os.Getenv("PAYGW_SIM_URL")
+	if simUrl == "" {
+		simUrl = "http://localhost:7010"
+	}
+	var reqBody []byte
+	if body != nil {
+		reqBody, _ = json.Marshal(body)
+	}
+	resp, err := http.Post(simUrl+path, "application/json", bytes.NewBuffer(reqBody))
+	require.Equal(t, nil, err, "Make sure paygw-sim is running and notifies the server")
+	defer resp.Body.Close()
+	require.Equal(t, 200, resp.StatusCode)
+
+	var payment simPaymentModel
+	require.Equal(t, nil, json.NewDecoder(resp.Body).Decode(&payment))
+	return payment
+}
+
+func TestTransactionWebhook_Pay(t *testing.T) {
+	session := getTestLoginSession(t)
+	require.NotEqual(t, "", session)
+	trx := createTestBilling(t, session)
+
+	code, response := notifyTestPayment(t, trx, "TEST-"+uuid.NewString(), trx.Amount)
+	require.Equal(t, 200, code)
+	require.Equal(t, schemas.STATUS_PAID, response.Data.Status)
+	require.Equal(t, trx.Amount, response.Data.Paid)
+
+	billing := detailTestBilling(t, session, trx)
+	require.Equal(t, schemas.STATUS_PAID, billing.Status)
+	require.Equal(t, trx.Amount, billing.Paid)
+	require.NotEqual(t, "", billing.OrderUuid)
+	require.NotNil(t, billing.Order)
+	require.Equal(t, billing.OrderUuid, billing.Order.Uuid)
+}
+
+func TestTransactionWebhook_PartialPay(t *testing.T) {
+	session := getTestLoginSession(t)
+	require.NotEqual(t, "", session)
+	trx := createTestBilling(t, session)
+	part := trx.Amount / 2
+
+	code, response := notifyTestPayment(t, trx, "TEST-"+uuid.NewString(), part)
+	require.Equal(t, 200, code)
+	require.Equal(t, schemas.STATUS_PARTIALLY_PAID, response.Data.Status)
+	require.Equal(t, part, response.Data.Paid)
+
+	billing := detailTestBilling(t, session, trx)
+	require.Equal(t, schemas.STATUS_PARTIALLY_PAID, billing.Status)
+	require.Equal(t, part, billing.Paid)
+	require.Equal(t, "", billing.OrderUuid)
+
+	// paid over the amount of the bill, the rest is credited to the user
+	code, response = notifyTestPayment(t, trx, "TEST-"+uuid.NewString(), trx.Amount-part+500)
+	require.Equal(t, 200, code)
+	require.Equal(t, schemas.STATUS_PAID, response.Data.Status)
+	require.Equal(t, trx.Amount+500, response.Data.Paid)
+	require.Equal(t, 500, response.Data.Credit)
+
+	billing = detailTestBilling(t, session, trx)
+	require.Equal(t, schemas.STATUS_PAID, billing.Status)
+	require.Equal(t, 500, billing.Credit)
+	require.NotNil(t, billing.Order)
+}
+
+func TestTransactionWebhook_RepeatNotification(t *testing.T) {
+	session := getTestLoginSession(t)
+	require.NotEqual(t, "", session)
+	trx := createTestBilling(t, session)
+	part := trx.Amount / 2
+	first := "TEST-" + uuid.NewString()
+	second := "TEST-" + uuid.NewString()
+
+	code, _ := notifyTestPayment(t, trx, first, part)
+	require.Equal(t, 200, code)
+	code, response := notifyTestPayment(t, trx, first, part)
+	require.Equal(t, 200, code)
+	require.Equal(t, schemas.STATUS_PARTIALLY_PAID, response.Data.Status)
+	require.Equal(t, part, response.Data.Paid)
+
+	code, _ = notifyTestPayment(t, trx, second, trx.Amount-part)
+	require.Equal(t, 200, code)
+	paid := detailTestBilling(t, session, trx)
+	require.Equal(t, schemas.STATUS_PAID, paid.Status)
+	require.NotNil(t, paid.Order)
+
+	// neither repeat may revert the payments already recorded
+	for _, reference := range []string{second, first} {
+		code, response = notifyTestPayment(t, trx, reference, trx.Amount-part)
+		require.Equal(t, 200, code)
+		require.Equal(t, schemas.STATUS_PAID, response.Data.Status)
+		require.Equal(t, trx.Amount, response.Data.Paid)
+	}
+
+	billing := detailTestBilling(t, session, trx)
+	require.Equal(t, schemas.STATUS_PAID, billing.Status)
+	require.Equal(t, trx.Amount, billing.Paid)
+	require.Equal(t, paid.OrderUuid, billing.OrderUuid)
+	require.NotNil(t, billing.Order)
+}
+
+func TestTransactionWebhook_Expired(t *testing.T) {
+	session := getTestLoginSession(t)
+	require.NotEqual(t, "", session)
+	trx := createTestBilling(t, session)
+	part := trx.Amount / 2
+
+	code, _ := notifyTestPayment(t, trx, "TEST-"+uuid.NewString(), part)
+	require.Equal(t, 200, code)
+
+	// the bill is moved past its deadline instead of waiting for it
+	db := database.InitDB()
+	defer db.Close()
+	_, err := db.Exec(`UPDATE "transaction" SET expired = $1 WHERE uuid = $2`, time.Now().Add(-time.Minute), trx.Uuid)
+	require.Equal(t, nil, err, "Make sure DATABASE_URI points to the database of the server")
+
+	code, _ = notifyTestPayment(t, trx, "TEST-"+uuid.NewString(), trx.Amount-part)
+	require.Equal(t, http.StatusGone, code)
+
+	// the bills past their deadline are expired every minute
+	deadline := time.Now().Add(2 * time.Minute)
+	billing := detailTestBilling(t, session, trx)
+	for billing.Status != schemas.STATUS_EXPIRED && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Second)
+		billing = detailTestBilling(t, session, trx)
+	}
+	require.Equal(t, schemas.STATUS_EXPIRED, billing.Status)
+	require.Equal(t, part, billing.Paid)
+	require.Equal(t, part, billing.Credit)
+	require.Equal(t, "", billing.OrderUuid)
+}
+
+func TestTransactionWebhook_Simulator(t *testing.T) {
+	session := getTestLoginSession(t)
+	require.NotEqual(t, "", session)
+	trx := createTestBilling(t, session)
+	part := trx.Amount / 2
+	path := fmt.Sprintf("/va/%s/%s/pay", trx.PaymentMethod, trx.VirtualAccount)
+
+	payment := simTestRequest(t, path, map[string]interface{}{"amount": part})
+	require.Equal(t, 200, payment.Data.NotifiedStatus)
+	require.Equal(t, part, payment.Data.Notification.Amount)
+	billing := detailTestBilling(t, session, trx)
+	require.Equal(t, schemas.STATUS_PARTIALLY_PAID, billing.Status)
+	require.Equal(t, part, billing.Paid)
+
+	// without an amount the simulator pays what is left of the bill
+	payment = simTestRequest(t, path, nil)
+	require.Equal(t, 200, payment.Data.NotifiedStatus)
+	require.Equal(t, trx.Amount-part, payment.Data.Notification.Amount)
+	billing = detailTestBilling(t, session, trx)
+	require.Equal(t, schemas.STATUS_PAID, billing.Status)
+	require.Equal(t, trx.Amount, billing.Paid)
+	require.NotNil(t, billing.Order)
+
+	// the bank sending the notification again changes nothing
+	payment = simTestRequest(t, "/payments/"+payment.Data.Notification.Reference+"/notify", nil)
+	require.Equal(t, 200, payment.Data.NotifiedStatus)
+	again := detailTestBilling(t, session, trx)
+	require.Equal(t, schemas.STATUS_PAID, again.Status)
+	require.Equal(t, trx.Amount, again.Paid)
+	require.Equal(t, billing.OrderUuid, again.OrderUuid)
+}
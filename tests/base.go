@@ -79,3 +79,22 @@ func getTestLoginSession(t *testing.T) string {
 	require.Equal(t, 0, len(response.Errors))
 	return response.Data.Login.Session
 }
+
+// graphqlRequest sends query to module with the session of the test user and
+// decodes the data of the response into data.
+func graphqlRequest(t *testing.T, module, session, query string, data interface{}) []grapqlErrorModel {
+	body, _ := json.Marshal(graphqlQueryModel{Query: query})
+	req, _ := http.NewRequest("POST", getUrl(t, module), bytes.NewBuffer(body))
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", session))
+	resp, err := http.DefaultClient.Do(req)
+	require.Equal(t, nil, err, "Make sure this test can access the server")
+	defer resp.Body.Close()
+
+	response := struct {
+		Data   interface{}        `json:"data"`
+		Errors []grapqlErrorModel `json:"errors"`
+	}{Data: data}
+	json.NewDecoder(resp.Body).Decode(&response)
+	return response.Errors
+}